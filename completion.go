@@ -0,0 +1,288 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completeArg is the hidden first argument with which completion scripts run
+// the program to complete the values of flags at runtime.
+const completeArg = "__complete"
+
+// Completion hints set with the complete tag.
+const (
+	completeFile = "file"
+	completeDir  = "dir"
+)
+
+// ErrComplete is returned by Configure after writing completions for the
+// hidden __complete argument, unless the flag set exits on error.
+var ErrComplete = errors.New("flagstruct: completions written")
+
+// Completer is implemented by values that complete their command-line
+// arguments at runtime, returning the candidates starting with prefix.
+type Completer interface {
+	Complete(prefix string) []string
+}
+
+// completion describes how the value of a flag is completed.
+type completion struct {
+	name      string
+	usage     string
+	isBool    bool
+	values    []string
+	hint      string
+	completer Completer
+}
+
+// completions returns how the values of the registered flags are completed,
+// in lexicographical order. Hidden and deprecated flags are left out.
+func (s *FlagSet) completions() []completion {
+	var a []completion
+
+	s.VisitAll(func(fl *flag.Flag) {
+		f := s.lookup(fl.Name)
+		if f != nil && (f.hidden || f.deprecated != "") {
+			return
+		}
+
+		c := completion{name: fl.Name}
+		_, c.usage = unquoteUsage(fl.Usage, nil)
+		c.usage = strings.SplitN(c.usage, "\n", 2)[0]
+
+		if b, ok := fl.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			c.isBool = true
+			c.values = []string{"true", "false"}
+		}
+
+		value := flag.Value(fl.Value)
+		if f != nil {
+			value = f.value
+			if len(f.oneof) > 0 {
+				c.values = f.oneof
+			}
+			c.hint = f.complete
+		}
+		c.completer, _ = value.(Completer)
+
+		a = append(a, c)
+	})
+
+	return a
+}
+
+// WriteCompletion writes a completion script for the given shell: "bash",
+// "zsh" or "fish". The script completes flag names, the values of boolean
+// flags, the values listed by oneof tags, and files or directories for flags
+// tagged complete:"file" or complete:"dir". The values of flags implementing
+// Completer are completed at runtime, by running the program with the hidden
+// __complete argument, which Configure handles, see ErrComplete. The
+// subcommands added with Subcommand complete the first argument that is not a
+// flag.
+func (s *FlagSet) WriteCompletion(w io.Writer, shell string) error {
+	var buf strings.Builder
+
+	switch shell {
+	case "bash":
+		s.writeBash(&buf)
+	case "zsh":
+		s.writeZsh(&buf)
+	case "fish":
+		s.writeFish(&buf)
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+func (s *FlagSet) writeBash(buf *strings.Builder) {
+	name := s.configName()
+	fn := "_" + shellIdent(name)
+	comps := s.completions()
+
+	var flags, bools []string
+	for _, c := range comps {
+		flags = append(flags, "-"+c.name)
+		if c.isBool {
+			bools = append(bools, c.name)
+		}
+	}
+
+	fmt.Fprintf(buf, "# bash completion for %s\n", name)
+	fmt.Fprintf(buf, "%s() {\n", fn)
+	buf.WriteString("\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} flag= next=\n")
+	buf.WriteString("\t# Words are split at =, as in -flag=value.\n")
+	buf.WriteString("\tif [[ $cur == = ]]; then\n")
+	buf.WriteString("\t\tflag=$prev cur=\n")
+	buf.WriteString("\telif [[ $prev == = ]]; then\n")
+	buf.WriteString("\t\tflag=${COMP_WORDS[COMP_CWORD-2]}\n")
+	buf.WriteString("\telif [[ $cur != -* && $prev == -* ]]; then\n")
+	buf.WriteString("\t\tflag=$prev next=1\n")
+	buf.WriteString("\tfi\n")
+	buf.WriteString("\tflag=${flag#-}\n")
+	buf.WriteString("\tflag=${flag#-}\n")
+	if len(bools) > 0 {
+		buf.WriteString("\t# Boolean flags only take values after =.\n")
+		fmt.Fprintf(buf, "\t[[ $next ]] && case $flag in %s) flag= ;; esac\n", strings.Join(bools, "|"))
+	}
+	buf.WriteString("\tcase $flag in\n")
+	for _, c := range comps {
+		var reply string
+		switch {
+		case c.completer != nil:
+			reply = fmt.Sprintf(`$("${COMP_WORDS[0]}" %s %s "$cur" 2>/dev/null)`, completeArg, shellQuote(c.name))
+		case len(c.values) > 0:
+			reply = fmt.Sprintf(`$(compgen -W %s -- "$cur")`, shellQuote(strings.Join(c.values, " ")))
+		case c.hint == completeFile:
+			reply = `$(compgen -f -- "$cur")`
+		case c.hint == completeDir:
+			reply = `$(compgen -d -- "$cur")`
+		}
+		fmt.Fprintf(buf, "\t%s) COMPREPLY=(%s) ;;\n", shellQuote(c.name), reply)
+	}
+	buf.WriteString("\t'') if [[ $cur == -* ]]; then\n")
+	fmt.Fprintf(buf, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(flags, " ")))
+	buf.WriteString("\telse\n")
+	if len(s.subcommands) > 0 {
+		var names, patterns []string
+		for _, c := range s.subcommands {
+			names = append(names, c.Name)
+			patterns = append(patterns, shellQuote(c.Name))
+		}
+		buf.WriteString("\t\t# Subcommands complete the first argument.\n")
+		buf.WriteString("\t\tlocal word\n")
+		buf.WriteString("\t\tfor word in \"${COMP_WORDS[@]:1:COMP_CWORD-1}\"; do\n")
+		fmt.Fprintf(buf, "\t\t\tcase $word in %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;; esac\n", strings.Join(patterns, "|"))
+		buf.WriteString("\t\tdone\n")
+		fmt.Fprintf(buf, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+	} else {
+		buf.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	}
+	buf.WriteString("\tfi ;;\n")
+	buf.WriteString("\tesac\n")
+	buf.WriteString("}\n")
+	fmt.Fprintf(buf, "complete -F %s %s\n", fn, shellQuote(name))
+}
+
+func (s *FlagSet) writeZsh(buf *strings.Builder) {
+	name := s.configName()
+
+	fmt.Fprintf(buf, "#compdef %s\n", name)
+	buf.WriteString("_arguments -S \\\n")
+	for _, c := range s.completions() {
+		desc := ""
+		if c.usage != "" {
+			desc = "[" + strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(c.usage) + "]"
+		}
+		if c.isBool {
+			fmt.Fprintf(buf, "\t%s \\\n", shellQuote("-"+c.name+desc))
+			continue
+		}
+
+		var action string
+		switch {
+		case c.completer != nil:
+			action = fmt.Sprintf(`{compadd -- ${(f)"$($words[1] %s %s $PREFIX 2>/dev/null)"}}`, completeArg, shellQuote(c.name))
+		case len(c.values) > 0:
+			action = "(" + strings.Join(c.values, " ") + ")"
+		case c.hint == completeFile:
+			action = "_files"
+		case c.hint == completeDir:
+			action = "_files -/"
+		}
+		spec := fmt.Sprintf("-%s=%s:%s:%s", c.name, desc, strings.ReplaceAll(c.name, ":", `\:`), action)
+		fmt.Fprintf(buf, "\t%s \\\n", shellQuote(spec))
+	}
+	if len(s.subcommands) > 0 {
+		var commands []string
+		for _, c := range s.subcommands {
+			desc := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(c.Usage)
+			commands = append(commands, fmt.Sprintf(`%s\:"%s"`, strings.ReplaceAll(c.Name, ":", `\:`), desc))
+		}
+		fmt.Fprintf(buf, "\t%s \\\n", shellQuote("1:command:(("+strings.Join(commands, " ")+"))"))
+	}
+	buf.WriteString("\t'*:file:_files'\n")
+}
+
+func (s *FlagSet) writeFish(buf *strings.Builder) {
+	name := shellQuote(s.configName())
+
+	fmt.Fprintf(buf, "# fish completion for %s\n", s.configName())
+	for _, c := range s.completions() {
+		fmt.Fprintf(buf, "complete -c %s -o %s", name, shellQuote(c.name))
+		if c.usage != "" {
+			fmt.Fprintf(buf, " -d %s", shellQuote(c.usage))
+		}
+
+		switch {
+		case c.isBool:
+		case c.completer != nil:
+			fmt.Fprintf(buf, " -x -a %s", shellQuote(fmt.Sprintf("(%s %s %s (commandline -ct))", s.configName(), completeArg, shellQuote(c.name))))
+		case len(c.values) > 0:
+			fmt.Fprintf(buf, " -x -a %s", shellQuote(strings.Join(c.values, " ")))
+		case c.hint == completeFile:
+			buf.WriteString(" -r -F")
+		case c.hint == completeDir:
+			buf.WriteString(" -x -a '(__fish_complete_directories (commandline -ct))'")
+		default:
+			buf.WriteString(" -x")
+		}
+		buf.WriteString("\n")
+	}
+	for _, c := range s.subcommands {
+		fmt.Fprintf(buf, "complete -c %s -f -n __fish_use_subcommand -a %s", name, shellQuote(c.Name))
+		if c.Usage != "" {
+			fmt.Fprintf(buf, " -d %s", shellQuote(c.Usage))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// complete writes the candidates for the value of a flag, starting with a
+// prefix, one per line. args holds the name of the flag and the prefix.
+func (s *FlagSet) complete(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	name := strings.TrimLeft(args[0], "-")
+	prefix := ""
+	if len(args) > 1 {
+		prefix = args[1]
+	}
+
+	var candidates []string
+	for _, c := range s.completions() {
+		if c.name != name {
+			continue
+		}
+		if c.completer != nil {
+			candidates = c.completer.Complete(prefix)
+			break
+		}
+		for _, v := range c.values {
+			if strings.HasPrefix(v, prefix) {
+				candidates = append(candidates, v)
+			}
+		}
+	}
+
+	for _, c := range candidates {
+		fmt.Fprintln(stdout, c)
+	}
+}
+
+// shellIdent returns s with characters not allowed in shell function names
+// replaced by underscores.
+func shellIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
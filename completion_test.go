@@ -0,0 +1,188 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type colorValue string
+
+func (c *colorValue) Set(s string) error { *c = colorValue(s); return nil }
+func (c *colorValue) Get() interface{}   { return string(*c) }
+func (c *colorValue) String() string     { return string(*c) }
+
+func (c *colorValue) Complete(prefix string) []string {
+	var a []string
+	for _, s := range []string{"red", "green", "blue"} {
+		if strings.HasPrefix(s, prefix) {
+			a = append(a, s)
+		}
+	}
+	return a
+}
+
+type completionConfig struct {
+	Level   string     `flag:"level" usage:"log [level]" oneof:"debug info warn"`
+	Verbose bool       `flag:"v" usage:"verbose output"`
+	Out     string     `flag:"out" usage:"output ~file~" complete:"file"`
+	Dir     string     `flag:"dir" complete:"dir"`
+	Color   colorValue `flag:"color" usage:"it's a color"`
+	Name    string     `flag:"name"`
+}
+
+func TestWriteCompletion(t *testing.T) {
+	s := NewFlagSet("/usr/bin/my-prog", flag.ContinueOnError)
+	s.Struct(&completionConfig{})
+
+	tests := map[string][]string{
+		"bash": {
+			"_my_prog() {\n",
+			"\t[[ $next ]] && case $flag in v) flag= ;; esac\n",
+			"\tcolor) COMPREPLY=($(\"${COMP_WORDS[0]}\" __complete color \"$cur\" 2>/dev/null)) ;;\n",
+			"\tdir) COMPREPLY=($(compgen -d -- \"$cur\")) ;;\n",
+			"\tlevel) COMPREPLY=($(compgen -W 'debug info warn' -- \"$cur\")) ;;\n",
+			"\tname) COMPREPLY=() ;;\n",
+			"\tout) COMPREPLY=($(compgen -f -- \"$cur\")) ;;\n",
+			"\tv) COMPREPLY=($(compgen -W 'true false' -- \"$cur\")) ;;\n",
+			"COMPREPLY=($(compgen -W '-color -dir -level -name -out -v' -- \"$cur\"))\n",
+			"complete -F _my_prog my-prog\n",
+		},
+		"zsh": {
+			"#compdef my-prog\n",
+			"\t'-color=[it'\\''s a color]:color:{compadd -- ${(f)\"$($words[1] __complete color $PREFIX 2>/dev/null)\"}}' \\\n",
+			"\t'-dir=:dir:_files -/' \\\n",
+			"\t'-level=[log \\[level\\]]:level:(debug info warn)' \\\n",
+			"\t-name=:name: \\\n",
+			"\t'-out=[output file]:out:_files' \\\n",
+			"\t'-v[verbose output]' \\\n",
+		},
+		"fish": {
+			"complete -c my-prog -o color -d 'it'\\''s a color' -x -a '(my-prog __complete color (commandline -ct))'\n",
+			"complete -c my-prog -o dir -x -a '(__fish_complete_directories (commandline -ct))'\n",
+			"complete -c my-prog -o level -d 'log [level]' -x -a 'debug info warn'\n",
+			"complete -c my-prog -o name -x\n",
+			"complete -c my-prog -o out -d 'output file' -r -F\n",
+			"complete -c my-prog -o v -d 'verbose output'\n",
+		},
+	}
+
+	for shell, lines := range tests {
+		buf := bytes.Buffer{}
+		err := s.WriteCompletion(&buf, shell)
+		if err != nil {
+			t.Errorf("WriteCompletion(%q) returned error %v", shell, err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("%s completion does not contain %q:\n%s", shell, line, buf.String())
+			}
+		}
+
+		if _, err := exec.LookPath(shell); err == nil {
+			cmd := exec.Command(shell, "-n")
+			cmd.Stdin = &buf
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s completion is invalid: %v\n%s", shell, err, out)
+			}
+		}
+	}
+
+	if err := s.WriteCompletion(&bytes.Buffer{}, "csh"); err == nil {
+		t.Error("expected error for unsupported shell")
+	}
+}
+
+func TestWriteCompletionSubcommands(t *testing.T) {
+	s := NewFlagSet("my-prog", flag.ContinueOnError)
+	s.Struct(&struct {
+		Verbose bool `flag:"v" usage:"verbose output"`
+	}{})
+	s.Subcommand("serve", `serve "the" files`)
+	s.Subcommand("check", "")
+
+	tests := map[string][]string{
+		"bash": {
+			"\t\t\tcase $word in serve|check) COMPREPLY=($(compgen -f -- \"$cur\")); return ;; esac\n",
+			"\t\tCOMPREPLY=($(compgen -W 'serve check' -- \"$cur\"))\n",
+		},
+		"zsh": {
+			"\t'1:command:((serve\\:\"serve \\\"the\\\" files\" check\\:\"\"))' \\\n",
+		},
+		"fish": {
+			"complete -c my-prog -f -n __fish_use_subcommand -a serve -d 'serve \"the\" files'\n",
+			"complete -c my-prog -f -n __fish_use_subcommand -a check\n",
+		},
+	}
+
+	for shell, lines := range tests {
+		buf := bytes.Buffer{}
+		err := s.WriteCompletion(&buf, shell)
+		if err != nil {
+			t.Errorf("WriteCompletion(%q) returned error %v", shell, err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("%s completion does not contain %q:\n%s", shell, line, buf.String())
+			}
+		}
+
+		if _, err := exec.LookPath(shell); err == nil {
+			cmd := exec.Command(shell, "-n")
+			cmd.Stdin = &buf
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s completion is invalid: %v\n%s", shell, err, out)
+			}
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	defer func() { stdout, exit = os.Stdout, os.Exit }()
+
+	tests := map[string][]string{
+		"-level i": {"info"},
+		"level":    {"debug", "info", "warn"},
+		"-v=f":     {"false"},
+		"color gr": {"green"},
+		"name x":   nil,
+		"":         nil,
+	}
+
+	for args, expected := range tests {
+		buf := bytes.Buffer{}
+		exitcode := -1
+		stdout, exit = &buf, func(code int) { exitcode = code }
+
+		s := NewFlagSet("program", flag.ContinueOnError)
+		err := s.Configure(&completionConfig{}, append([]string{"__complete"}, strings.Fields(strings.ReplaceAll(args, "=", " "))...))
+		if err != ErrComplete || exitcode != -1 {
+			t.Errorf("completing %q returned error %v and exit code %d", args, err, exitcode)
+		}
+
+		actual := strings.Fields(buf.String())
+		if strings.Join(actual, " ") != strings.Join(expected, " ") {
+			t.Errorf("completing %q returned %q, expected %q", args, actual, expected)
+		}
+	}
+
+	exitcode := -1
+	stdout, exit = io.Discard, func(code int) { exitcode = code }
+	s := NewFlagSet("program", flag.ExitOnError)
+	s.Configure(&completionConfig{}, []string{"__complete", "level"})
+	if exitcode != 0 {
+		t.Errorf("completing with ExitOnError exited with code %d", exitcode)
+	}
+
+	s = NewFlagSet("program", flag.ContinueOnError)
+	err := s.Struct(&struct {
+		Out string `flag:"out" complete:"files"`
+	}{})
+	if err == nil {
+		t.Error("expected error for invalid completion hint")
+	}
+}
@@ -0,0 +1,429 @@
+package flagstruct
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configExts lists the supported configuration file extensions, in the order
+// they are searched for.
+var configExts = []string{".json", ".yaml", ".yml", ".toml"}
+
+// configDecoders decodes configuration files into tables keyed by flag name.
+// Tables hold nested tables and configValue leaves. JSON with comments is only
+// read from files loaded explicitly, such as samples written by WriteSample.
+var configDecoders = map[string]func([]byte) (map[string]interface{}, error){
+	".json":  decodeJSON,
+	".jsonc": decodeJSONC,
+	".yaml":  decodeYAML,
+	".yml":   decodeYAML,
+	".toml":  decodeTOML,
+}
+
+// configValue is a scalar or an array read from a configuration file, along
+// with its position. Scalars are kept as strings, to be parsed by Value.Set.
+type configValue struct {
+	value     interface{} // nil, string or []configValue
+	line, col int
+}
+
+// posError is an error at a position in a configuration file.
+type posError struct {
+	line, col int
+	msg       string
+}
+
+func (e *posError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.line, e.col, e.msg)
+}
+
+// position returns the line and column of the byte offset off in b.
+func position(b []byte, off int) (line, col int) {
+	if off > len(b) {
+		off = len(b)
+	}
+	line = 1 + bytes.Count(b[:off], []byte{'\n'})
+	col = off - bytes.LastIndexByte(b[:off], '\n')
+	return line, col
+}
+
+func decodeJSON(b []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	v, err := decodeJSONValue(d, b)
+	if err == nil && d.More() {
+		err = errors.New("unexpected data after top-level value")
+	}
+	if err != nil {
+		if _, ok := err.(*posError); ok {
+			return nil, err
+		}
+		off := int(d.InputOffset())
+		if serr, ok := err.(*json.SyntaxError); ok {
+			off = int(serr.Offset)
+		}
+		line, col := position(b, off)
+		return nil, &posError{line, col, err.Error()}
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &posError{v.(configValue).line, v.(configValue).col, "expected an object"}
+	}
+	return m, nil
+}
+
+// decodeJSONC decodes JSON with // and /* */ comments.
+func decodeJSONC(b []byte) (map[string]interface{}, error) {
+	c := make([]byte, len(b))
+	copy(c, b)
+
+	// Blank out comments, keeping newlines so positions are unchanged.
+	for i := 0; i < len(c); i++ {
+		switch {
+		case c[i] == '"':
+			for i++; i < len(c) && c[i] != '"'; i++ {
+				if c[i] == '\\' {
+					i++
+				}
+			}
+		case bytes.HasPrefix(c[i:], []byte("//")):
+			for ; i < len(c) && c[i] != '\n'; i++ {
+				c[i] = ' '
+			}
+		case bytes.HasPrefix(c[i:], []byte("/*")):
+			end := bytes.Index(c[i+2:], []byte("*/"))
+			if end < 0 {
+				line, col := position(b, i)
+				return nil, &posError{line, col, "unterminated comment"}
+			}
+			for j := i; j < i+end+4; j++ {
+				if c[j] != '\n' {
+					c[j] = ' '
+				}
+			}
+			i += end + 3
+		}
+	}
+
+	return decodeJSON(c)
+}
+
+func decodeJSONValue(d *json.Decoder, b []byte) (interface{}, error) {
+	// Skip to the start of the next token to find its position.
+	off := int(d.InputOffset())
+	for off < len(b) && strings.IndexByte(" \t\r\n:,", b[off]) >= 0 {
+		off++
+	}
+	line, col := position(b, off)
+
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '{' {
+			m := map[string]interface{}{}
+			for d.More() {
+				key, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSONValue(d, b)
+				if err != nil {
+					return nil, err
+				}
+				m[key.(string)] = v
+			}
+			_, err = d.Token()
+			return m, err
+		}
+
+		var a []configValue
+		for d.More() {
+			v, err := decodeJSONValue(d, b)
+			if err != nil {
+				return nil, err
+			}
+			cv, ok := v.(configValue)
+			if !ok {
+				return nil, &posError{line, col, "objects in arrays are not supported"}
+			}
+			a = append(a, cv)
+		}
+		_, err = d.Token()
+		return configValue{a, line, col}, err
+	case nil:
+		return configValue{nil, line, col}, nil
+	default:
+		return configValue{fmt.Sprint(tok), line, col}, nil
+	}
+}
+
+// DefaultConfigPaths returns the directories searched for configuration files
+// of the program name, from lowest to highest precedence: /etc/<name>,
+// $XDG_CONFIG_HOME/<name> (~/.config/<name> if unset), the working directory
+// and the directories listed in $<NAME>_CONFIG_PATH.
+func DefaultConfigPaths(name string) []string {
+	return defaultConfigPaths(name, os.LookupEnv)
+}
+
+// defaultConfigPaths is DefaultConfigPaths, looking up environment variables
+// with lookupEnv.
+func defaultConfigPaths(name string, lookupEnv func(key string) (string, bool)) []string {
+	dirs := []string{filepath.Join("/etc", name)}
+
+	if xdg, _ := lookupEnv("XDG_CONFIG_HOME"); xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, name))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", name))
+	}
+
+	dirs = append(dirs, ".")
+
+	list, _ := lookupEnv(envKey(name) + "_CONFIG_PATH")
+	for _, dir := range filepath.SplitList(list) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// SetConfigPaths enables loading configuration files from the directories
+// dirs, given from lowest to highest precedence. If no directories are given,
+// DefaultConfigPaths is used, with the environment looked up as set by
+// WithLookupEnv. Configuration files are named after the flag set, for example
+// "program.json".
+func (s *FlagSet) SetConfigPaths(dirs ...string) {
+	if len(dirs) == 0 {
+		dirs = defaultConfigPaths(s.configName(), s.lookupEnvFunc)
+	}
+	s.configPaths = dirs
+}
+
+// SetConfigFS sets the file system configuration files are read from. Paths
+// are resolved to absolute paths, then looked up without the leading slash.
+// If fsys is nil, the root of the operating system's file system is used.
+func (s *FlagSet) SetConfigFS(fsys fs.FS) {
+	s.configFS = fsys
+}
+
+// ConfigFiles returns the configuration files and key directories loaded so
+// far, in order.
+func (s *FlagSet) ConfigFiles() []string {
+	return s.configFiles
+}
+
+// ParseConfig loads the configuration files found in the directories set with
+// SetConfigPaths, then the directories set with SetKeyDirs. Values in later
+// files override those in earlier ones.
+func (s *FlagSet) ParseConfig() error {
+	name := s.configName()
+
+	for _, dir := range s.configPaths {
+		for _, ext := range configExts {
+			err := s.loadConfig(filepath.Join(dir, name+ext), "")
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return s.failf("%v", err)
+			}
+		}
+	}
+
+	for _, dir := range s.keyDirs {
+		err := s.loadKeyDir(dir)
+		if err != nil {
+			return s.failf("%v", err)
+		}
+	}
+
+	return nil
+}
+
+// LoadConfig loads the configuration file at path, as an explicit -config
+// flag would. The format is chosen by the file extension.
+func (s *FlagSet) LoadConfig(path string) error {
+	return s.LoadConfigFormat(path, "")
+}
+
+// LoadConfigFormat loads the configuration file at path in the given format:
+// "json", "jsonc" (JSON with comments), "yaml" or "toml". An empty format is
+// chosen by the file extension.
+func (s *FlagSet) LoadConfigFormat(path, format string) error {
+	err := s.loadConfig(path, format)
+	if err != nil {
+		return s.failf("%v", err)
+	}
+	return nil
+}
+
+func (s *FlagSet) loadConfig(p, format string) error {
+	ext := filepath.Ext(p)
+	if format != "" {
+		ext = "." + format
+	}
+	decode, ok := configDecoders[ext]
+	if !ok {
+		return fmt.Errorf("%s: unsupported configuration format %q", p, strings.TrimPrefix(ext, "."))
+	}
+
+	p, b, err := s.readFile(p)
+	if err != nil {
+		return err
+	}
+
+	m, err := decode(b)
+	if err != nil {
+		return fmt.Errorf("%s:%v", p, err)
+	}
+
+	err = s.setValues(m, p)
+	if err != nil {
+		return err
+	}
+
+	s.configFiles = append(s.configFiles, p)
+	return nil
+}
+
+// readFile reads the file at p from the configuration file system, returning
+// its absolute path along with its contents.
+func (s *FlagSet) readFile(p string) (string, []byte, error) {
+	fsys, p, name, err := s.resolve(p)
+	if err != nil {
+		return p, nil, err
+	}
+
+	b, err := fs.ReadFile(fsys, name)
+	return p, b, err
+}
+
+// resolve returns the configuration file system along with the absolute path
+// of p and its name within the file system.
+func (s *FlagSet) resolve(p string) (fsys fs.FS, abs, name string, err error) {
+	abs, err = filepath.Abs(p)
+	if err != nil {
+		return nil, p, "", err
+	}
+
+	fsys = s.configFS
+	if fsys == nil {
+		fsys = os.DirFS("/")
+	}
+
+	name = strings.TrimPrefix(filepath.ToSlash(abs), "/")
+	if name == "" {
+		name = "."
+	}
+	return fsys, abs, name, nil
+}
+
+// setValues sets the fields named by the flag names in the table m. Nested
+// tables name the members of nested structs. Errors are prefixed by origin,
+// which is also recorded as the origin of the values.
+func (s *FlagSet) setValues(m map[string]interface{}, origin string) error {
+	return s.setTable(m, "", origin)
+}
+
+func (s *FlagSet) setTable(m map[string]interface{}, prefix, origin string) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var err error
+		switch v := m[key].(type) {
+		case map[string]interface{}:
+			err = s.setTable(v, prefix+key+".", origin)
+		case configValue:
+			err = s.setValue(prefix+key, v, origin)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FlagSet) setValue(name string, v configValue, origin string) error {
+	// Null values leave the default in place.
+	if v.value == nil {
+		return nil
+	}
+
+	f := s.lookup(name)
+	if f == nil {
+		return fmt.Errorf("%s:%d:%d: unknown flag %q", origin, v.line, v.col, name)
+	}
+
+	var err error
+	switch x := v.value.(type) {
+	case string:
+		err = f.set(x)
+	case []configValue:
+		_, ok := f.value.(sliceValue)
+		if !ok {
+			return fmt.Errorf("%s:%d:%d: flag %q does not take a list", origin, v.line, v.col, name)
+		}
+		vals := make([]string, len(x))
+		for i, e := range x {
+			vals[i], ok = e.value.(string)
+			if !ok {
+				return fmt.Errorf("%s:%d:%d: invalid element for flag %q", origin, e.line, e.col, name)
+			}
+		}
+		err = f.update(func(v Value) error {
+			return v.(sliceValue).setSlice(vals)
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%d:%d: invalid value for flag %q: %v", origin, v.line, v.col, name, err)
+	}
+
+	s.setOrigin(f, origin, fmt.Sprintf("key %s in %s", name, origin))
+	return nil
+}
+
+// lookup returns the field registered under the flag name, if any.
+func (s *FlagSet) lookup(name string) *field {
+	for _, f := range s.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// envKey returns name in upper case, with dashes and dots replaced by
+// underscores, for use in environment keys.
+func envKey(name string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name))
+}
+
+// configName returns the base name of configuration files.
+func (s *FlagSet) configName() string {
+	return path.Base(filepath.ToSlash(s.name))
+}
@@ -0,0 +1,190 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDefaultConfigPaths(t *testing.T) {
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	os.Setenv("MY_APP_CONFIG_PATH", "/a"+string(filepath.ListSeparator)+"/b")
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+	defer os.Unsetenv("MY_APP_CONFIG_PATH")
+
+	dirs := DefaultConfigPaths("my-app")
+	expected := []string{"/etc/my-app", "/xdg/my-app", ".", "/a", "/b"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("DefaultConfigPaths returned %q, expected %q", dirs, expected)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	conf := struct {
+		Host    string `flag:"host"`
+		Port    int    `flag:"port" env:"CONFIG_TEST_PORT"`
+		Debug   bool   `flag:"debug"`
+		Timeout int    `flag:"timeout"`
+	}{Timeout: 5}
+
+	fsys := fstest.MapFS{
+		"etc/program/program.json":  {Data: []byte(`{"host": "etc", "port": 80, "debug": true}`)},
+		"home/program/program.json": {Data: []byte(`{"host": "home"}`)},
+	}
+
+	os.Setenv("CONFIG_TEST_PORT", "8080")
+	defer os.Unsetenv("CONFIG_TEST_PORT")
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("/usr/bin/program", flag.ContinueOnError)
+	s.SetOutput(&buf)
+	s.SetConfigFS(fsys)
+	s.SetConfigPaths("/etc/program", "/missing", "/home/program")
+	err := s.Configure(&conf, []string{"-debug=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Host != "home" || conf.Port != 8080 || conf.Debug || conf.Timeout != 5 {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	files := []string{"/etc/program/program.json", "/home/program/program.json"}
+	if !reflect.DeepEqual(s.ConfigFiles(), files) {
+		t.Errorf("ConfigFiles returned %q, expected %q", s.ConfigFiles(), files)
+	}
+
+	if origin := s.Origin("Host"); origin != "/home/program/program.json" {
+		t.Errorf("unexpected origin %q", origin)
+	}
+
+	s.Usage()
+	if !strings.HasSuffix(buf.String(), "\nConfiguration files:\n  /etc/program/program.json\n  /home/program/program.json\n") {
+		t.Errorf("usage does not list configuration files:\n%s", buf.String())
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	conf := struct {
+		Port int `flag:"port"`
+	}{}
+
+	fsys := fstest.MapFS{
+		"good.json":    {Data: []byte(`{"port": 1}`)},
+		"bad.json":     {Data: []byte(`{"port": "x"}`)},
+		"unknown.json": {Data: []byte(`{"other": 1}`)},
+		"nested.json":  {Data: []byte(`{"port": [1]}`)},
+		"syntax.json":  {Data: []byte(`{`)},
+		"conf.ini":     {Data: []byte(`port=1`)},
+	}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&bytes.Buffer{})
+	s.SetConfigFS(fsys)
+	s.Struct(&conf)
+
+	err := s.LoadConfig("/good.json")
+	if err != nil || conf.Port != 1 {
+		t.Errorf("LoadConfig returned %v, port %d", err, conf.Port)
+	}
+
+	for _, p := range []string{"/bad.json", "/unknown.json", "/nested.json", "/syntax.json", "/conf.ini", "/missing.json"} {
+		err = s.LoadConfig(p)
+		if err == nil {
+			t.Errorf("expected error loading %s", p)
+		}
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	type dbConfig struct {
+		User  string   `flag:"user"`
+		Hosts []string `flag:"hosts"`
+	}
+	conf := struct {
+		Port  int      `flag:"port"`
+		Ports []int    `flag:"ports"`
+		DB    dbConfig `flag:"db"`
+	}{Ports: []int{1}}
+
+	fsys := fstest.MapFS{
+		"conf.json":  {Data: []byte(`{"port": 1, "ports": [2, 3], "db": {"user": "json", "hosts": ["a"]}}`)},
+		"conf.jsonc": {Data: []byte("{\n  // \"port\": 0,\n  \"port\": 5, /* \"db\": {} */\n  \"db\": {\"user\": \"//jsonc\"}\n}\n")},
+		"conf.yaml":  {Data: []byte("port: 2\ndb:\n  user: yaml\n  hosts: [b, c]\n")},
+		"conf.toml":  {Data: []byte("port = 3\n[db]\nuser = \"toml\"\nhosts = [\"d\"]\n")},
+		"conf.txt":   {Data: []byte("port = 4\ndb.user = \"txt\"\n")},
+	}
+
+	tests := []struct {
+		path, format string
+		port         int
+		user         string
+		hosts        []string
+	}{
+		{"/conf.json", "", 1, "json", []string{"a"}},
+		{"/conf.jsonc", "", 5, "//jsonc", []string{"a"}},
+		{"/conf.yaml", "", 2, "yaml", []string{"b", "c"}},
+		{"/conf.toml", "", 3, "toml", []string{"d"}},
+		{"/conf.txt", "toml", 4, "txt", []string{"d"}},
+	}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.Struct(&conf)
+
+	for _, test := range tests {
+		err := s.LoadConfigFormat(test.path, test.format)
+		if err != nil {
+			t.Errorf("LoadConfigFormat(%q, %q) returned error %v", test.path, test.format, err)
+		}
+		if conf.Port != test.port || conf.DB.User != test.user || !reflect.DeepEqual(conf.DB.Hosts, test.hosts) {
+			t.Errorf("unexpected configuration %+v after loading %s", conf, test.path)
+		}
+	}
+
+	if !reflect.DeepEqual(conf.Ports, []int{2, 3}) {
+		t.Errorf("unexpected ports %v", conf.Ports)
+	}
+
+	if origin := s.Origin("DB.User"); origin != "/conf.txt" {
+		t.Errorf("unexpected origin %q", origin)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	conf := struct {
+		Port  int    `flag:"port"`
+		Ports []int  `flag:"ports"`
+		Name  string `flag:"name"`
+	}{}
+
+	tests := []struct {
+		data, err string
+	}{
+		{"{\n  \"port\": \"x\"\n}", `/conf.json:2:11: invalid value for flag "port": strconv.ParseInt: parsing "x": invalid syntax`},
+		{"{\n  \"ports\": [1, \"x\"]\n}", `/conf.json:2:12: invalid value for flag "ports": strconv.ParseInt: parsing "x": invalid syntax`},
+		{"{\"name\": [1]}", `/conf.json:1:10: flag "name" does not take a list`},
+		{"{\"ports\": [[1]]}", `/conf.json:1:12: invalid element for flag "ports"`},
+		{"{\n\"other\": 1}", `/conf.json:2:10: unknown flag "other"`},
+		{"{\n\"port\": }", `/conf.json:2:`},
+		{"[1]", `/conf.json:1:1: expected an object`},
+		{"{} {}", `/conf.json:1:4: unexpected data after top-level value`},
+	}
+
+	for _, test := range tests {
+		s := NewFlagSet("program", flag.ContinueOnError)
+		s.SetOutput(&bytes.Buffer{})
+		s.SetConfigFS(fstest.MapFS{"conf.json": {Data: []byte(test.data)}})
+		s.Struct(&conf)
+
+		err := s.LoadConfig("/conf.json")
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("loading %q returned error %v, expected %v", test.data, err, test.err)
+		}
+	}
+}
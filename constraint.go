@@ -0,0 +1,145 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parseConstraints reads the oneof, min and max tags of a struct member into
+// f, checking that they apply to its type.
+func (f *field) parseConstraints(sf structField) error {
+	if oneof := sf.Tag.Get("oneof"); oneof != "" {
+		f.oneof = strings.Fields(oneof)
+
+		// The values apply to the elements of slices.
+		e := f.value
+		if sv, ok := e.(sliceValue); ok {
+			e, _ = valueFromPointer(reflect.New(sv.v.Type().Elem()).Interface())
+		}
+		for _, s := range f.oneof {
+			if _, ok := canonical(e, s); !ok {
+				return fmt.Errorf("invalid oneof value %q for %s", s, sf.path)
+			}
+		}
+	}
+
+	f.min, f.max = sf.Tag.Get("min"), sf.Tag.Get("max")
+	for _, bound := range []string{f.min, f.max} {
+		if bound == "" {
+			continue
+		}
+		if _, _, ok := measure(f.value); !ok {
+			return fmt.Errorf("min and max are not supported for %s", sf.path)
+		}
+		if _, err := parseBound(f.value, bound); err != nil {
+			return fmt.Errorf("invalid bound %q for %s", bound, sf.path)
+		}
+	}
+
+	return nil
+}
+
+// check returns an error if v, a value of f, does not satisfy its
+// constraints.
+func (f *field) check(v Value) error {
+	if len(f.oneof) > 0 {
+		for _, e := range elements(v) {
+			if !oneOf(e, f.oneof) {
+				return fmt.Errorf("must be one of %s", strings.Join(f.oneof, ", "))
+			}
+		}
+	}
+
+	n, what, _ := measure(v)
+	if f.min != "" {
+		if b, _ := parseBound(v, f.min); n < b {
+			return fmt.Errorf("%s must be at least %s", what, f.min)
+		}
+	}
+	if f.max != "" {
+		if b, _ := parseBound(v, f.max); n > b {
+			return fmt.Errorf("%s must be at most %s", what, f.max)
+		}
+	}
+
+	return nil
+}
+
+// elements returns the elements of a slice value, or the value itself.
+func elements(v Value) []Value {
+	sv, ok := v.(sliceValue)
+	if !ok {
+		return []Value{v}
+	}
+
+	a := make([]Value, sv.v.Len())
+	for i := range a {
+		a[i], _ = valueFromPointer(sv.v.Index(i).Addr().Interface())
+	}
+	return a
+}
+
+// oneOf reports whether v equals one of the values in a.
+func oneOf(v Value, a []string) bool {
+	for _, s := range a {
+		if c, _ := canonical(v, s); c == v.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical returns s as formatted by a value of the type of v, so that equal
+// values compare equal. ok is false if s is not a valid value of the type.
+func canonical(v Value, s string) (c string, ok bool) {
+	t := reflect.TypeOf(v.Get())
+	if t == nil {
+		return s, true
+	}
+	cv, err := valueFromPointer(reflect.New(t).Interface())
+	if err != nil {
+		return s, true
+	}
+	if err = cv.Set(s); err != nil {
+		return s, false
+	}
+	return cv.String(), true
+}
+
+// measure returns the quantity bounded by the min and max tags of v: the
+// number itself, the length of a string or the number of elements of a slice,
+// along with a description of it.
+func measure(v Value) (n float64, what string, ok bool) {
+	switch x := v.Get().(type) {
+	case time.Duration:
+		return float64(x), "value", true
+	case string:
+		return float64(utf8.RuneCountInString(x)), "length", true
+	}
+
+	rv := reflect.ValueOf(v.Get())
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int64:
+		return float64(rv.Int()), "value", true
+	case reflect.Uint, reflect.Uint64:
+		return float64(rv.Uint()), "value", true
+	case reflect.Float64:
+		return rv.Float(), "value", true
+	case reflect.Slice:
+		return float64(rv.Len()), "number of elements", true
+	}
+	return 0, "", false
+}
+
+// parseBound parses a min or max tag of v.
+func parseBound(v Value, s string) (float64, error) {
+	if _, ok := v.Get().(time.Duration); ok {
+		d, err := time.ParseDuration(s)
+		return float64(d), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,125 @@
+package flagstruct
+
+import (
+	"flag"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type constraintConfig struct {
+	Level   string        `flag:"level" env:"CONSTRAINT_LEVEL" oneof:"debug info warn"`
+	Port    int           `flag:"port" min:"1" max:"65535"`
+	Timeout time.Duration `flag:"timeout" min:"1s" max:"1m"`
+	Name    string        `flag:"name" min:"2"`
+	Modes   []int         `flag:"modes" oneof:"1 2 3" max:"2"`
+}
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-level=info", "-port=80", "-timeout=1m", "-name=ab", "-modes=1,3"}, ""},
+		{[]string{"-level=trace"}, "must be one of debug, info, warn"},
+		{[]string{"-port=0"}, "value must be at least 1"},
+		{[]string{"-port=65536"}, "value must be at most 65535"},
+		{[]string{"-timeout=500ms"}, "value must be at least 1s"},
+		{[]string{"-name=a"}, "length must be at least 2"},
+		{[]string{"-modes=1,4"}, "must be one of 1, 2, 3"},
+		{[]string{"-modes=1,2,3"}, "number of elements must be at most 2"},
+	}
+
+	for _, test := range tests {
+		s := NewFlagSet("program", flag.ContinueOnError)
+		s.SetOutput(io.Discard)
+		conf := &constraintConfig{Level: "info", Port: 1, Timeout: time.Second}
+		s.Struct(conf)
+		err := s.Parse(test.args)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.HasSuffix(err.Error(), test.err)) {
+			t.Errorf("Parse(%q) returned error %v, expected %q", test.args, err, test.err)
+		}
+
+		// Rejected values are not assigned.
+		expected := &constraintConfig{Level: "info", Port: 1, Timeout: time.Second}
+		if test.err != "" && !reflect.DeepEqual(conf, expected) {
+			t.Errorf("Parse(%q) changed the configuration to %+v", test.args, conf)
+		}
+	}
+}
+
+func TestConstraintSources(t *testing.T) {
+	os.Setenv("CONSTRAINT_LEVEL", "trace")
+	defer os.Unsetenv("CONSTRAINT_LEVEL")
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.Struct(&constraintConfig{})
+	err := s.ParseEnv()
+	if err == nil || err.Error() != "$CONSTRAINT_LEVEL: invalid value: must be one of debug, info, warn" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	s = NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(io.Discard)
+	s.SetConfigFS(fstest.MapFS{"conf.json": {Data: []byte(`{"modes": [1, 2, 3]}`)}})
+	s.Struct(&constraintConfig{})
+	err = s.LoadConfig("/conf.json")
+	if err == nil || !strings.HasSuffix(err.Error(), "number of elements must be at most 2") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestBadConstraints(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			Port int `flag:"port" oneof:"1 a"`
+		}{},
+		&struct {
+			Port int `flag:"port" min:"a"`
+		}{},
+		&struct {
+			Enabled bool `flag:"enabled" max:"1"`
+		}{},
+	}
+
+	for _, conf := range tests {
+		s := NewFlagSet("program", flag.ContinueOnError)
+		if err := s.Struct(conf); err == nil {
+			t.Errorf("expected error for %+v", conf)
+		}
+	}
+}
+
+func TestRequired(t *testing.T) {
+	tests := []struct {
+		args []string
+		env  map[string]string
+		err  string
+	}{
+		{[]string{"-name=api"}, map[string]string{"REQUIRED_TOKEN": "x"}, ""},
+		{nil, map[string]string{"REQUIRED_TOKEN": "x"}, "required flag -name not set"},
+		{[]string{"-name=api"}, nil, "required environment variable $REQUIRED_TOKEN not set"},
+	}
+
+	for _, test := range tests {
+		lookupEnv := func(key string) (string, bool) {
+			v, ok := test.env[key]
+			return v, ok
+		}
+		s := NewFlagSet("program", flag.ContinueOnError, WithOutput(io.Discard), WithLookupEnv(lookupEnv))
+		conf := struct {
+			Name  string `flag:"name" required:"true"`
+			Token string `env:"REQUIRED_TOKEN" required:"true"`
+			Port  int    `flag:"port" required:"true"`
+		}{Port: 80}
+
+		err := s.Configure(&conf, test.args)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("%v: expected error %q, got %v", test.args, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A DefaultPolicy sets how Struct handles members tagged with default that
+// already have a non-zero value in the struct.
+type DefaultPolicy int
+
+const (
+	// DefaultKeepValue keeps the value in the struct. This is the default.
+	DefaultKeepValue DefaultPolicy = iota
+	// DefaultUseTag replaces the value in the struct with the tag.
+	DefaultUseTag
+	// DefaultConflictError makes Struct fail.
+	DefaultConflictError
+)
+
+// SetDefaultPolicy sets how Struct handles members tagged with default that
+// already have a non-zero value in the struct.
+func (s *FlagSet) SetDefaultPolicy(policy DefaultPolicy) {
+	s.defaultPolicy = policy
+}
+
+// applyDefault sets the value of f from the default tag of its member, if
+// any, before other sources are parsed.
+func (s *FlagSet) applyDefault(f *field, sf structField) error {
+	def, ok := sf.Tag.Lookup("default")
+	if !ok {
+		return nil
+	}
+
+	if !sf.value.IsZero() {
+		switch s.defaultPolicy {
+		case DefaultKeepValue:
+			return nil
+		case DefaultConflictError:
+			return fmt.Errorf("default %q for %s conflicts with value %s", def, sf.path, f.value.String())
+		}
+	}
+
+	err := f.set(def)
+	if err != nil {
+		return fmt.Errorf("invalid default %q for %s: %v", def, sf.path, err)
+	}
+	return nil
+}
+
+// withDefault returns the value of a struct member, or the value of its
+// default tag if it is zero, so defaults can be described before Struct
+// applies them.
+func withDefault(sf structField) reflect.Value {
+	def, ok := sf.Tag.Lookup("default")
+	if !ok || !sf.value.IsZero() {
+		return sf.value
+	}
+
+	p := reflect.New(sf.value.Type())
+	v, err := valueFromPointer(p.Interface())
+	if err != nil || v.Set(def) != nil {
+		return sf.value
+	}
+	return p.Elem()
+}
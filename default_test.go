@@ -0,0 +1,68 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+type defaultConfig struct {
+	Timeout time.Duration `flag:"timeout" default:"30s" usage:"request timeout"`
+	Name    string        `flag:"name" default:"api"`
+	Tags    []string      `flag:"tags" default:"a,b"`
+	Level   string        `flag:"level" default:"info" oneof:"debug info"`
+}
+
+func TestDefaultTag(t *testing.T) {
+	conf := defaultConfig{Name: "web"}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	err := s.Configure(&conf, []string{"-level=debug"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if conf.Timeout != 30*time.Second || conf.Name != "web" || len(conf.Tags) != 2 || conf.Level != "debug" {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+	if s.Origin("Timeout") != originDefault {
+		t.Errorf("unexpected origin %q", s.Origin("Timeout"))
+	}
+
+	buf.Reset()
+	s.PrintStruct(&defaultConfig{})
+	expected := "  -timeout duration\n    \trequest timeout (default 30s)\n" +
+		"  -name string\n    \t (default \"api\")\n" +
+		"  -tags value\n    \t (default a,b)\n" +
+		"  -level string\n    \t (default \"info\")\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	conf := defaultConfig{Name: "web"}
+	s := NewFlagSet("program", flag.ContinueOnError, WithDefaultPolicy(DefaultUseTag))
+	err := s.Struct(&conf)
+	if err != nil || conf.Name != "api" {
+		t.Errorf("unexpected result %+v, %v", conf, err)
+	}
+
+	conf = defaultConfig{Name: "web"}
+	s = NewFlagSet("program", flag.ContinueOnError, WithDefaultPolicy(DefaultConflictError))
+	err = s.Struct(&conf)
+	if err == nil || err.Error() != `default "api" for Name conflicts with value web` {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	bad := struct {
+		Level string `flag:"level" default:"trace" oneof:"debug info"`
+	}{}
+	s = NewFlagSet("program", flag.ContinueOnError, WithOutput(io.Discard))
+	err = s.Struct(&bad)
+	if err == nil {
+		t.Error("expected error for default not allowed by oneof")
+	}
+}
@@ -0,0 +1,111 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A Change describes a member whose value differs between two
+// configurations. Values are rendered by the String method of their Value,
+// and redacted for members tagged secret:"true".
+type Change struct {
+	// Path is the path of the member, such as "DB.Host".
+	Path string
+	Old  string
+	New  string
+}
+
+// Changes lists the members that differ between two configurations, in the
+// order of the struct.
+type Changes []Change
+
+// String formats the changes on one line, suitable for logging.
+func (c Changes) String() string {
+	if len(c) == 0 {
+		return "no changes"
+	}
+	parts := make([]string, len(c))
+	for i, ch := range c {
+		parts[i] = fmt.Sprintf("%s: %q -> %q", ch.Path, ch.Old, ch.New)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Paths returns the paths of the changed members.
+func (c Changes) Paths() []string {
+	var paths []string
+	for _, ch := range c {
+		paths = append(paths, ch.Path)
+	}
+	return paths
+}
+
+// Diff compares the flag and environment members of the structs pointed to by
+// old and new, which must be of the same type, and returns the members that
+// differ.
+func Diff(old, new interface{}) (Changes, error) {
+	var changes Changes
+	err := diff(old, new, walkStruct, func(sf structField, ch Change) {
+		changes = append(changes, ch)
+	})
+	return changes, err
+}
+
+// diff compares old and new as Diff does, walking new with walk, and calls fn
+// with each member that differs and its change.
+func diff(old, new interface{}, walk func(conf interface{}, fn func(sf structField) error) error, fn func(sf structField, ch Change)) error {
+	if reflect.TypeOf(old) != reflect.TypeOf(new) {
+		return fmt.Errorf("cannot compare %T with %T", old, new)
+	}
+	if reflect.ValueOf(old).Kind() != reflect.Ptr || reflect.ValueOf(old).IsNil() {
+		return notStructError{old}
+	}
+
+	vo := reflect.ValueOf(old).Elem()
+	return walk(new, func(sf structField) error {
+		if sf.name == "" && sf.env == "" {
+			return nil
+		}
+		o := fieldByPath(vo, sf.path)
+		if reflect.DeepEqual(o.Interface(), sf.value.Interface()) {
+			return nil
+		}
+
+		ch := Change{Path: sf.path, Old: redacted, New: redacted}
+		if sf.tag("secret") != "true" {
+			ch.Old, ch.New = valueString(o), valueString(sf.value)
+		}
+		fn(sf, ch)
+		return nil
+	})
+}
+
+// DiffDefaults returns the members loaded by Struct whose values differ from
+// their defaults.
+func (s *FlagSet) DiffDefaults() Changes {
+	var changes Changes
+	for _, f := range s.fields {
+		if reflect.DeepEqual(f.initial.Interface(), f.member.Interface()) {
+			continue
+		}
+
+		ch := Change{Path: f.path, Old: redacted, New: redacted}
+		if !f.secret {
+			ch.Old, ch.New = valueString(f.initial), f.value.String()
+		}
+		changes = append(changes, ch)
+	}
+	return changes
+}
+
+// valueString renders the value of a struct member with the String method of
+// its Value.
+func valueString(v reflect.Value) string {
+	c := copyValue(v)
+	val, err := valueFromPointer(c.Addr().Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return val.String()
+}
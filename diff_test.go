@@ -0,0 +1,66 @@
+package flagstruct
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type diffConfig struct {
+	Name     string        `flag:"name"`
+	Timeout  time.Duration `flag:"timeout"`
+	Tags     []string      `flag:"tags"`
+	Password string        `env:"PASSWORD" secret:"true"`
+	Internal int
+	DB       struct {
+		Host string `flag:"host"`
+	} `flag:"db"`
+}
+
+func TestDiff(t *testing.T) {
+	a := &diffConfig{Name: "api", Timeout: time.Second, Password: "a", Internal: 1}
+	b := &diffConfig{Name: "api", Timeout: time.Minute, Tags: []string{"x", "y"}, Password: "b", Internal: 2}
+	b.DB.Host = "db"
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := Changes{
+		{"Timeout", "1s", "1m0s"},
+		{"Tags", "", "x,y"},
+		{"Password", redacted, redacted},
+		{"DB.Host", "", "db"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("changes differ.\nexpected: %+v\nactual:   %+v", expected, changes)
+	}
+
+	str := `Timeout: "1s" -> "1m0s", Tags: "" -> "x,y", Password: "<redacted>" -> "<redacted>", DB.Host: "" -> "db"`
+	if changes.String() != str {
+		t.Errorf("unexpected string %s", changes.String())
+	}
+	if (Changes{}).String() != "no changes" {
+		t.Errorf("unexpected string %s", Changes{}.String())
+	}
+
+	_, err = Diff(a, &struct{}{})
+	if err == nil {
+		t.Error("expected error for different types")
+	}
+}
+
+func TestDiffDefaults(t *testing.T) {
+	conf := &diffConfig{Name: "api"}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	err := s.Configure(conf, []string{"-name=web", "-timeout=1s"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := Changes{{"Name", "api", "web"}, {"Timeout", "0s", "1s"}}
+	if changes := s.DiffDefaults(); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("changes differ.\nexpected: %+v\nactual:   %+v", expected, changes)
+	}
+}
@@ -0,0 +1,310 @@
+package flagstruct
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// docEntry is a member or a section documented by WriteMarkdown and
+// WriteManPage.
+type docEntry struct {
+	section     bool
+	title       string
+	description string
+	name        string
+	env         string
+	typn        string
+	usage       string
+	def         string
+	constraints []string
+}
+
+// docEntries collects the members of the struct passed to conf, along with
+// the titles of its sections, grouped as by PrintStruct. Hidden and deprecated
+// members are left out. Other flags follow, if enabled.
+func (s *FlagSet) docEntries(conf interface{}) ([]docEntry, error) {
+	var entries []docEntry
+	var nested *structField
+
+	err := s.walk(conf, func(sf structField) error {
+		if sf.Name == "_" {
+			if title, description := groupTitle(&sf); title != "" {
+				entries = append(entries, docEntry{section: true, title: title, description: description})
+			}
+			return nil
+		}
+		if sf.name == "" && sf.env == "" {
+			return nil
+		}
+
+		if sf.tag("hidden") == "true" || sf.Tag.Get("deprecated") != "" {
+			return nil
+		}
+
+		// Nested structs with a group tag form sections, followed by an
+		// untitled one.
+		if g := groupOf(&sf); g != nested {
+			nested = g
+			title, description := "", ""
+			if g != nil {
+				title, description = groupTitle(g)
+			}
+			entries = append(entries, docEntry{section: true, title: title, description: description})
+		}
+
+		e := docEntry{name: sf.name, env: sf.env}
+		e.typn, e.usage, e.def = describe(sf)
+		if sf.tag("secret") == "true" {
+			if e.def != "" {
+				e.def = redacted
+			}
+			e.constraints = append(e.constraints, "secret")
+		}
+		if sf.Tag.Get("required") == "true" {
+			e.constraints = append(e.constraints, "required")
+		}
+		if oneof := strings.Fields(sf.Tag.Get("oneof")); len(oneof) > 0 {
+			e.constraints = append(e.constraints, "one of "+strings.Join(oneof, ", "))
+		}
+		if min := sf.Tag.Get("min"); min != "" {
+			e.constraints = append(e.constraints, "min "+min)
+		}
+		if max := sf.Tag.Get("max"); max != "" {
+			e.constraints = append(e.constraints, "max "+max)
+		}
+		if policy := sf.tag("reload"); policy != "" {
+			e.constraints = append(e.constraints, "reload: "+policy)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+
+	if g := s.otherGroup(); s.docOtherFlags && g != nil {
+		entries = append(entries, docEntry{section: true, title: g.title})
+		for _, e := range g.entries {
+			entries = append(entries, docEntry{name: e.name, typn: e.typn, usage: e.usage, def: e.def})
+		}
+	}
+
+	return entries, err
+}
+
+// SetDocOtherFlags sets whether WriteMarkdown and WriteManPage document the
+// flags not loaded from structs, such as those defined with the flag package,
+// with their default values and usage, under "Other flags".
+func (s *FlagSet) SetDocOtherFlags(document bool) {
+	s.docOtherFlags = document
+}
+
+// WriteMarkdown writes the documentation of the struct passed to conf as
+// Markdown tables listing the flag, environment variable, type, default value,
+// usage and constraints of each member. Groups start a new table under a
+// heading, followed by their description. The subcommands added with
+// Subcommand are listed in a last table, under "Commands".
+func (s *FlagSet) WriteMarkdown(w io.Writer, conf interface{}) error {
+	entries, err := s.docEntries(conf)
+	if err != nil {
+		return err
+	}
+
+	var buf strings.Builder
+	header := true
+	for _, e := range entries {
+		if e.section {
+			if e.title != "" {
+				if buf.Len() > 0 {
+					buf.WriteString("\n")
+				}
+				fmt.Fprintf(&buf, "### %s\n", markdownEscape(e.title))
+			}
+			if e.description != "" {
+				fmt.Fprintf(&buf, "\n%s\n", strings.ReplaceAll(markdownEscape(e.description), "<br>", "\n"))
+			}
+			header = true
+			continue
+		}
+
+		if header {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("| Flag | Environment | Type | Default | Description | Constraints |\n")
+			buf.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+			header = false
+		}
+
+		cells := []string{
+			markdownCode(e.name, "-"),
+			markdownCode(e.env, ""),
+			markdownEscape(e.typn),
+			markdownCode(e.def, ""),
+			markdownEscape(e.usage),
+			markdownEscape(strings.Join(e.constraints, ", ")),
+		}
+		fmt.Fprintf(&buf, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	if len(s.subcommands) > 0 {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("### Commands\n\n| Command | Description |\n| --- | --- |\n")
+		for _, c := range s.subcommands {
+			fmt.Fprintf(&buf, "| %s | %s |\n", markdownCode(c.Name, ""), markdownEscape(c.Usage))
+		}
+	}
+
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
+
+// markdownCode formats s as inline code in a table cell, with a prefix.
+func markdownCode(s, prefix string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.NewReplacer("|", `\|`, "\n", " ").Replace(prefix + s)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// markdownEscape escapes s for use in a table cell.
+func markdownEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "\n", "<br>")
+	return r.Replace(s)
+}
+
+// WriteManPage writes the documentation of the struct passed to conf as a
+// roff man page in section 1, with NAME, SYNOPSIS, COMMANDS, if subcommands
+// were added with Subcommand, OPTIONS and ENVIRONMENT sections. The
+// description is used in the NAME section. Groups start a subsection of
+// OPTIONS.
+func (s *FlagSet) WriteManPage(w io.Writer, conf interface{}, description string) error {
+	entries, err := s.docEntries(conf)
+	if err != nil {
+		return err
+	}
+
+	name := s.configName()
+	var buf strings.Builder
+	fmt.Fprintf(&buf, ".TH %s 1\n", roffQuote(strings.ToUpper(name)))
+	buf.WriteString(".SH NAME\n")
+	if description != "" {
+		fmt.Fprintf(&buf, "%s \\- %s\n", roffEscape(name), roffEscape(description))
+	} else {
+		fmt.Fprintf(&buf, "%s\n", roffEscape(name))
+	}
+	buf.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&buf, ".B %s\n[\\fIoptions\\fR]", roffEscape(name))
+	if len(s.subcommands) > 0 {
+		buf.WriteString(" \\fIcommand\\fR")
+	}
+	buf.WriteString("\n")
+
+	if len(s.subcommands) > 0 {
+		buf.WriteString(".SH COMMANDS\n")
+		for _, c := range s.subcommands {
+			fmt.Fprintf(&buf, ".TP\n.B %s\n", roffQuote(c.Name))
+			buf.WriteString(roffLine(c.Usage))
+		}
+	}
+
+	buf.WriteString(".SH OPTIONS\n")
+	for _, e := range entries {
+		if e.section {
+			if e.title != "" {
+				fmt.Fprintf(&buf, ".SS %s\n", roffQuote(e.title))
+			}
+			buf.WriteString(roffLine(e.description))
+			continue
+		}
+		if e.name == "" {
+			continue
+		}
+
+		buf.WriteString(".TP\n")
+		if e.typn != "" {
+			fmt.Fprintf(&buf, ".BI %s \" %s\"\n", roffQuote("-"+e.name), roffEscape(e.typn))
+		} else {
+			fmt.Fprintf(&buf, ".B %s\n", roffQuote("-"+e.name))
+		}
+		buf.WriteString(roffLine(e.usage + docNotes(e, false)))
+	}
+
+	env := false
+	for _, e := range entries {
+		if e.env == "" {
+			continue
+		}
+		if !env {
+			buf.WriteString(".SH ENVIRONMENT\n")
+			env = true
+		}
+
+		buf.WriteString(".TP\n")
+		fmt.Fprintf(&buf, ".B %s\n", roffEscape(e.env))
+		usage := e.usage
+		if e.name != "" {
+			usage = strings.TrimSuffix(usage, ".")
+			if usage != "" {
+				usage += ". "
+			}
+			usage += "Same as -" + e.name + "."
+		}
+		buf.WriteString(roffLine(usage + docNotes(e, true)))
+	}
+
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
+
+// docNotes returns the default value and constraints of e as a parenthesized
+// suffix. The default is omitted if it is documented elsewhere.
+func docNotes(e docEntry, env bool) string {
+	var notes []string
+	if e.def != "" && (!env || e.name == "") {
+		notes = append(notes, "default "+e.def)
+	}
+	notes = append(notes, e.constraints...)
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, "; ") + ")"
+}
+
+// roffEscape escapes backslashes and dashes in s.
+func roffEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+// roffQuote escapes s as a macro argument.
+func roffQuote(s string) string {
+	s = roffEscape(s)
+	if strings.ContainsAny(s, " \t\"") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+// roffLine escapes s as text lines, protecting lines starting with a control
+// character.
+func roffLine(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+
+	var buf strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		line = roffEscape(line)
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			buf.WriteString(`\&`)
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
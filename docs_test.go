@@ -0,0 +1,228 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	conf := struct {
+		Name     string        `flag:"name" env:"DOCS_NAME" usage:"~name~ of the service | app" required:"true"`
+		Verbose  bool          `flag:"v" usage:"verbose output"`
+		_        struct{}      `group:"Database" description:"Primary *database*."`
+		Timeout  time.Duration `flag:"db.timeout" env:"DOCS_DB_TIMEOUT" usage:".5s or more" min:"500ms" reload:"static"`
+		Password string        `env:"DOCS_DB_PASSWORD" secret:"true"`
+	}{Name: "api", Timeout: time.Second, Password: "hunter2"}
+
+	expected := "| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-name` | `DOCS_NAME` | name | `\"api\"` | name of the service \\| app | required |\n" +
+		"| `-v` |  |  |  | verbose output |  |\n" +
+		"\n" +
+		"### Database\n" +
+		"\n" +
+		"Primary \\*database\\*.\n" +
+		"\n" +
+		"| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-db.timeout` | `DOCS_DB_TIMEOUT` | duration | `1s` | .5s or more | min 500ms, reload: static |\n" +
+		"|  | `DOCS_DB_PASSWORD` | string | `<redacted>` |  | secret |\n"
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	err := s.WriteMarkdown(&buf, &conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("unexpected output.\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteManPage(t *testing.T) {
+	conf := struct {
+		Name     string        `flag:"name" env:"DOCS_NAME" usage:"~name~ of the service | app" required:"true"`
+		Verbose  bool          `flag:"v" usage:"verbose output"`
+		_        struct{}      `group:"Database" description:"Primary *database*."`
+		Timeout  time.Duration `flag:"db.timeout" env:"DOCS_DB_TIMEOUT" usage:".5s or more" min:"500ms" reload:"static"`
+		Password string        `env:"DOCS_DB_PASSWORD" secret:"true"`
+	}{Name: "api", Timeout: time.Second, Password: "hunter2"}
+
+	expected := `.TH PROGRAM 1
+.SH NAME
+program \- run the service
+.SH SYNOPSIS
+.B program
+[\fIoptions\fR]
+.SH OPTIONS
+.TP
+.BI \-name " name"
+name of the service | app (default "api"; required)
+.TP
+.B \-v
+verbose output
+.SS Database
+Primary *database*.
+.TP
+.BI \-db.timeout " duration"
+\&.5s or more (default 1s; min 500ms; reload: static)
+.SH ENVIRONMENT
+.TP
+.B DOCS_NAME
+name of the service | app. Same as \-name. (required)
+.TP
+.B DOCS_DB_TIMEOUT
+\&.5s or more. Same as \-db.timeout. (min 500ms; reload: static)
+.TP
+.B DOCS_DB_PASSWORD
+(default <redacted>; secret)
+`
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("/usr/bin/program", flag.ContinueOnError)
+	err := s.WriteManPage(&buf, &conf, "run the service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("unexpected output.\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestOtherFlags(t *testing.T) {
+	conf := struct {
+		Name string `flag:"name" usage:"service name"`
+	}{}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	s.String("log_dir", "/tmp", "log ~directory~")
+	s.Int("v", 0, "log level")
+	err := s.Configure(&conf, []string{"-help"})
+	if err != flag.ErrHelp {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := "Usage of program:\n" +
+		"  -name string\n    \tservice name\n" +
+		"\n" +
+		"Other flags:\n" +
+		"  -log_dir directory\n    \tlog directory (default \"/tmp\")\n" +
+		"  -v int\n    \tlog level\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expected, buf.String())
+	}
+
+	buf.Reset()
+	s.SetDocOtherFlags(true)
+	err = s.WriteMarkdown(&buf, &conf)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected = "| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-name` |  | string |  | service name |  |\n" +
+		"\n" +
+		"### Other flags\n" +
+		"\n" +
+		"| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-log_dir` |  | directory | `\"/tmp\"` | log directory |  |\n" +
+		"| `-v` |  | int |  | log level |  |\n"
+	if buf.String() != expected {
+		t.Errorf("markdown differs from expected.\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteMarkdownNestedGroup(t *testing.T) {
+	type dbConfig struct {
+		Host string `flag:"host" usage:"database host"`
+	}
+	conf := struct {
+		Verbose bool     `flag:"v" usage:"verbose output"`
+		DB      dbConfig `flag:"db" group:"Database"`
+		Debug   bool     `flag:"debug" usage:"debug output"`
+	}{}
+
+	expected := "| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-v` |  |  |  | verbose output |  |\n" +
+		"\n" +
+		"### Database\n" +
+		"\n" +
+		"| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-db.host` |  | string |  | database host |  |\n" +
+		"\n" +
+		"| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-debug` |  |  |  | debug output |  |\n"
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	err := s.WriteMarkdown(&buf, &conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("unexpected output.\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestDocsSubcommands(t *testing.T) {
+	conf := struct {
+		Verbose bool `flag:"v" usage:"verbose output"`
+	}{}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.Subcommand("serve", "serve the *files*")
+	s.Subcommand("check", "check the configuration")
+
+	buf := bytes.Buffer{}
+	err := s.WriteMarkdown(&buf, &conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "| Flag | Environment | Type | Default | Description | Constraints |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| `-v` |  |  |  | verbose output |  |\n" +
+		"\n" +
+		"### Commands\n" +
+		"\n" +
+		"| Command | Description |\n" +
+		"| --- | --- |\n" +
+		"| `serve` | serve the \\*files\\* |\n" +
+		"| `check` | check the configuration |\n"
+	if buf.String() != expected {
+		t.Errorf("markdown differs from expected.\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	err = s.WriteManPage(&buf, &conf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `.TH PROGRAM 1
+.SH NAME
+program
+.SH SYNOPSIS
+.B program
+[\fIoptions\fR] \fIcommand\fR
+.SH COMMANDS
+.TP
+.B serve
+serve the *files*
+.TP
+.B check
+check the configuration
+.SH OPTIONS
+.TP
+.B \-v
+verbose output
+`
+	if buf.String() != expected {
+		t.Errorf("man page differs from expected.\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
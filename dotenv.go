@@ -0,0 +1,243 @@
+package flagstruct
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// dotenvValue is a value read from a dotenv file.
+type dotenvValue struct {
+	value  string
+	origin string
+}
+
+// LoadDotenv loads environment variables from the dotenv files at paths, in
+// order, without modifying the environment of the process. Missing files are
+// ignored. The variables are used by ParseEnv when the environment does not
+// define them, unless SetDotenvOverride is used.
+//
+// Each line of a dotenv file is a comment or a KEY=value assignment, optionally
+// prefixed by "export". Values may be single-quoted, taken literally, or
+// double-quoted, allowing escapes such as \n and \". Unquoted values end at a
+// comment. Double-quoted and unquoted values expand references to variables,
+// written $VAR or ${VAR}.
+func (s *FlagSet) LoadDotenv(paths ...string) error {
+	s.dotenvPaths = append(s.dotenvPaths, paths...)
+
+	for _, p := range paths {
+		p, b, err := s.readFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return s.failf("%v", err)
+		}
+
+		err = s.parseDotenv(string(b), p)
+		if err != nil {
+			return s.failf("%v", err)
+		}
+	}
+
+	return nil
+}
+
+// SetDotenvOverride sets whether variables loaded by LoadDotenv take precedence
+// over the environment of the process. By default, they do not.
+func (s *FlagSet) SetDotenvOverride(override bool) {
+	s.dotenvOverride = override
+}
+
+// lookupEnv looks up the environment variable key in the environment of the
+// process, or the one set with WithLookupEnv, and in loaded dotenv files,
+// returning its value and origin.
+func (s *FlagSet) lookupEnv(key string) (value, origin string, ok bool) {
+	d, dok := s.dotenv[key]
+	if dok && s.dotenvOverride {
+		return d.value, d.origin, true
+	}
+	if v, ok := s.lookupEnvFunc(key); ok {
+		return v, "$" + key, true
+	}
+	return d.value, d.origin, dok
+}
+
+// parseDotenv parses the contents of the dotenv file at p.
+func (s *FlagSet) parseDotenv(src, p string) error {
+	if s.dotenv == nil {
+		s.dotenv = map[string]dotenvValue{}
+	}
+
+	line := 1
+	for len(src) > 0 {
+		// Skip blank lines and comments.
+		src = strings.TrimLeft(src, " \t\r")
+		if strings.HasPrefix(src, "#") {
+			if i := strings.IndexByte(src, '\n'); i >= 0 {
+				src = src[i:]
+			} else {
+				src = ""
+			}
+		}
+		if strings.HasPrefix(src, "\n") {
+			src = src[1:]
+			line++
+			continue
+		}
+		if src == "" {
+			break
+		}
+
+		start := line
+		if strings.HasPrefix(src, "export ") || strings.HasPrefix(src, "export\t") {
+			src = strings.TrimLeft(src[7:], " \t")
+		}
+
+		i := 0
+		for i < len(src) && isEnvKeyByte(src[i], i) {
+			i++
+		}
+		key := src[:i]
+		src = strings.TrimLeft(src[i:], " \t")
+		if key == "" || !strings.HasPrefix(src, "=") {
+			return fmt.Errorf("%s:%d: expected KEY=value", p, line)
+		}
+		src = strings.TrimLeft(src[1:], " \t")
+
+		var value string
+		var err error
+		valueSrc := src
+		switch {
+		case strings.HasPrefix(src, "'"):
+			i = strings.IndexByte(src[1:], '\'')
+			if i < 0 {
+				return fmt.Errorf("%s:%d: unterminated quote", p, start)
+			}
+			value, src = src[1:i+1], src[i+2:]
+		case strings.HasPrefix(src, `"`):
+			value, src, err = s.dotenvQuoted(src[1:])
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", p, start, err)
+			}
+		default:
+			i = strings.IndexByte(src, '\n')
+			if i < 0 {
+				i = len(src)
+			}
+			value, src = src[:i], src[i:]
+			if i = strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			value, err = s.expandEnv(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", p, start, err)
+			}
+		}
+		line += strings.Count(valueSrc[:len(valueSrc)-len(src)], "\n")
+
+		// Only a comment may follow a quoted value.
+		rest := src
+		if i = strings.IndexByte(src, '\n'); i >= 0 {
+			rest, src = src[:i], src[i:]
+		} else {
+			src = ""
+		}
+		rest = strings.TrimSpace(rest)
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return fmt.Errorf("%s:%d: unexpected %q after value", p, line, rest)
+		}
+
+		s.dotenv[key] = dotenvValue{value, p}
+	}
+
+	return nil
+}
+
+// dotenvQuoted parses a double-quoted value, after its opening quote.
+func (s *FlagSet) dotenvQuoted(src string) (value, rest string, err error) {
+	var buf strings.Builder
+
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			value, err = s.expandEnv(buf.String())
+			return value, src[i+1:], err
+		case '\\':
+			if i++; i == len(src) {
+				break
+			}
+			switch src[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case '"', '\\':
+				buf.WriteByte(src[i])
+			case '$':
+				// Escape the dollar sign from expansion.
+				buf.WriteString("$$")
+			default:
+				buf.WriteByte('\\')
+				buf.WriteByte(src[i])
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return "", "", errors.New("unterminated quote")
+}
+
+// expandEnv replaces $VAR and ${VAR} in v with the values of the variables, as
+// looked up by lookupEnv. $$ is replaced by a literal dollar sign, as is a
+// dollar sign escaped in a double-quoted value.
+func (s *FlagSet) expandEnv(v string) (string, error) {
+	var buf strings.Builder
+
+	for i := 0; i < len(v); i++ {
+		if v[i] != '$' || i+1 == len(v) {
+			buf.WriteByte(v[i])
+			continue
+		}
+
+		var key string
+		switch {
+		case v[i+1] == '$':
+			buf.WriteByte('$')
+			i++
+			continue
+		case v[i+1] == '{':
+			j := strings.IndexByte(v[i:], '}')
+			if j < 0 {
+				return "", errors.New("unterminated ${")
+			}
+			key = v[i+2 : i+j]
+			i += j
+		default:
+			j := i + 1
+			for j < len(v) && isEnvKeyByte(v[j], j-i-1) {
+				j++
+			}
+			key = v[i+1 : j]
+			i = j - 1
+		}
+
+		if key == "" {
+			buf.WriteByte('$')
+			continue
+		}
+		val, _, _ := s.lookupEnv(key)
+		buf.WriteString(val)
+	}
+
+	return buf.String(), nil
+}
+
+// isEnvKeyByte reports whether c may appear at position i of a variable name.
+func isEnvKeyByte(c byte, i int) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9'
+}
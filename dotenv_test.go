@@ -0,0 +1,107 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadDotenv(t *testing.T) {
+	env := `# Comment
+export DOTENV_A=plain value # comment
+DOTENV_B = 'single $DOTENV_A # not a comment'
+DOTENV_C="double\t\"${DOTENV_A}\" \$DOTENV_A
+second line"   # comment
+DOTENV_D=$DOTENV_REAL-${DOTENV_MISSING}$
+DOTENV_E=
+`
+	fsys := fstest.MapFS{
+		"app/.env":       {Data: []byte(env)},
+		"app/.env.local": {Data: []byte("DOTENV_E=local\nDOTENV_REAL=dotenv\n")},
+	}
+
+	os.Setenv("DOTENV_REAL", "real")
+	defer os.Unsetenv("DOTENV_REAL")
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	err := s.LoadDotenv("/app/.env", "/app/.env.missing", "/app/.env.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"DOTENV_A":    "plain value",
+		"DOTENV_B":    "single $DOTENV_A # not a comment",
+		"DOTENV_C":    "double\t\"plain value\" $DOTENV_A\nsecond line",
+		"DOTENV_D":    "real-$",
+		"DOTENV_E":    "local",
+		"DOTENV_REAL": "real",
+	}
+	for key, value := range expected {
+		v, _, _ := s.lookupEnv(key)
+		if v != value {
+			t.Errorf("%s = %q, expected %q", key, v, value)
+		}
+	}
+
+	if _, ok := os.LookupEnv("DOTENV_A"); ok {
+		t.Error("expected process environment to be unchanged")
+	}
+
+	s.SetDotenvOverride(true)
+	if v, origin, _ := s.lookupEnv("DOTENV_REAL"); v != "dotenv" || origin != "/app/.env.local" {
+		t.Errorf("unexpected override %q from %q", v, origin)
+	}
+}
+
+func TestDotenvParseEnv(t *testing.T) {
+	conf := struct {
+		Name  string `env:"DOTENV_NAME"`
+		Level int    `env:"DOTENV_LEVEL"`
+	}{}
+
+	os.Setenv("DOTENV_LEVEL", "2")
+	defer os.Unsetenv("DOTENV_LEVEL")
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fstest.MapFS{".env": {Data: []byte("DOTENV_NAME=dotenv\nDOTENV_LEVEL=1\n")}})
+	s.LoadDotenv("/.env")
+	err := s.Configure(&conf, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Name != "dotenv" || conf.Level != 2 {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	if origin := s.Origin("Name"); origin != "/.env" {
+		t.Errorf("unexpected origin %q", origin)
+	}
+}
+
+func TestDotenvErrors(t *testing.T) {
+	tests := []struct {
+		data, err string
+	}{
+		{"A=1\nB\n", "/.env:2: expected KEY=value"},
+		{"A=1\n1A=2\n", "/.env:2: expected KEY=value"},
+		{"A='x\n", "/.env:1: unterminated quote"},
+		{"A=\"x\ny\n", "/.env:1: unterminated quote"},
+		{"A=\"x\ny\" z\n", "/.env:2: unexpected \"z\" after value"},
+		{"A=${B\n", "/.env:1: unterminated ${"},
+	}
+
+	for _, test := range tests {
+		s := NewFlagSet("program", flag.ContinueOnError)
+		s.SetOutput(&bytes.Buffer{})
+		s.SetConfigFS(fstest.MapFS{".env": {Data: []byte(test.data)}})
+		err := s.LoadDotenv("/.env")
+		if err == nil || err.Error() != test.err {
+			t.Errorf("loading %q returned error %v, expected %v", test.data, err, test.err)
+		}
+	}
+}
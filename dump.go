@@ -0,0 +1,227 @@
+package flagstruct
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// redacted replaces the values of secret members in comments written by Dump.
+const redacted = "<redacted>"
+
+// Dump writes the current values of the members loaded by Struct in the given
+// format:
+//
+//   - "json": a JSON configuration file, keyed by flag name.
+//   - "yaml": a YAML configuration file, keyed by flag name.
+//   - "env": a dotenv file, keyed by environment key.
+//   - "args": command-line arguments, quoted for a POSIX shell.
+//
+// Nested structs are written as nested objects. The output can be loaded back
+// with LoadConfig, LoadDotenv or SetArgsEnv respectively, to reproduce the
+// configuration. Members tagged secret:"true" are left out; the YAML and env
+// formats list them in comments, with their values redacted.
+func (s *FlagSet) Dump(w io.Writer, format string) error {
+	var buf strings.Builder
+
+	switch format {
+	case "json":
+		writeJSON(&buf, s.dumpTree(), "")
+		buf.WriteByte('\n')
+	case "yaml":
+		writeYAML(&buf, s.dumpTree(), "")
+	case "env":
+		for _, f := range s.fields {
+			switch {
+			case f.env == "":
+			case f.secret:
+				fmt.Fprintf(&buf, "# %s=%s\n", f.env, redacted)
+			default:
+				fmt.Fprintf(&buf, "%s=%s\n", f.env, dotenvQuote(f.value.String()))
+			}
+		}
+	case "args":
+		args := s.DumpArgs()
+		for i, arg := range args {
+			args[i] = shellQuote(arg)
+		}
+		buf.WriteString(strings.Join(args, " "))
+		buf.WriteByte('\n')
+	default:
+		return fmt.Errorf("unsupported dump format %q", format)
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// DumpArgs returns command-line arguments setting every flag to its current
+// value. Flags of members tagged secret:"true" are left out.
+func (s *FlagSet) DumpArgs() []string {
+	var args []string
+	for _, f := range s.fields {
+		if f.name != "" && !f.secret {
+			args = append(args, "-"+f.name+"="+f.value.String())
+		}
+	}
+	return args
+}
+
+// dumpNode is a table or a member in the tree of flag names written by Dump.
+type dumpNode struct {
+	key      string
+	field    *field
+	children []*dumpNode
+}
+
+// dumpTree builds the tree of flag names. Names are split into tables at
+// dots, unless a prefix of the name is a flag name itself.
+func (s *FlagSet) dumpTree() *dumpNode {
+	root := &dumpNode{}
+
+	for _, f := range s.fields {
+		if f.name == "" {
+			continue
+		}
+
+		parts := strings.Split(f.name, ".")
+		n, i := root, 0
+		for ; i < len(parts)-1 && s.lookup(strings.Join(parts[:i+1], ".")) == nil; i++ {
+			n = n.table(parts[i])
+		}
+		n.children = append(n.children, &dumpNode{key: strings.Join(parts[i:], "."), field: f})
+	}
+
+	return root
+}
+
+// table returns the child table of n named key, adding it if needed.
+func (n *dumpNode) table(key string) *dumpNode {
+	for _, c := range n.children {
+		if c.key == key && c.field == nil {
+			return c
+		}
+	}
+	c := &dumpNode{key: key}
+	n.children = append(n.children, c)
+	return c
+}
+
+func writeJSON(buf *strings.Builder, n *dumpNode, indent string) {
+	buf.WriteString("{")
+	first := true
+	for _, c := range n.children {
+		if c.field != nil && c.field.secret {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		key, _ := json.Marshal(c.key)
+		fmt.Fprintf(buf, "\n%s  %s: ", indent, key)
+		if c.field == nil {
+			writeJSON(buf, c, indent+"  ")
+			continue
+		}
+		buf.WriteString(jsonValue(c.field.value))
+	}
+	if !first {
+		buf.WriteString("\n" + indent)
+	}
+	buf.WriteString("}")
+}
+
+// jsonValue returns the JSON representation of v. Booleans and numbers are
+// kept as such, other values are written as strings.
+func jsonValue(v Value) string {
+	if sv, ok := v.(sliceValue); ok {
+		a := make([]string, sv.v.Len())
+		for i := range a {
+			e, _ := valueFromPointer(sv.v.Index(i).Addr().Interface())
+			a[i] = jsonValue(e)
+		}
+		return "[" + strings.Join(a, ", ") + "]"
+	}
+
+	var b []byte
+	switch x := v.Get().(type) {
+	case bool, int, int64, uint, uint64, float64:
+		b, _ = json.Marshal(x)
+	default:
+		b, _ = json.Marshal(v.String())
+	}
+	return string(b)
+}
+
+func writeYAML(buf *strings.Builder, n *dumpNode, indent string) {
+	for _, c := range n.children {
+		key := yamlQuote(c.key)
+		switch {
+		case c.field == nil:
+			fmt.Fprintf(buf, "%s%s:\n", indent, key)
+			writeYAML(buf, c, indent+"  ")
+		case c.field.secret:
+			fmt.Fprintf(buf, "%s# %s: %s\n", indent, key, redacted)
+		default:
+			fmt.Fprintf(buf, "%s%s: %s\n", indent, key, yamlValue(c.field.value))
+		}
+	}
+}
+
+// yamlValue returns the YAML representation of v, as a scalar or a flow
+// sequence.
+func yamlValue(v Value) string {
+	sv, ok := v.(sliceValue)
+	if !ok {
+		return yamlQuote(v.String())
+	}
+
+	a := make([]string, sv.v.Len())
+	for i := range a {
+		e, _ := valueFromPointer(sv.v.Index(i).Addr().Interface())
+		a[i] = yamlQuote(e.String())
+		if strings.Contains(a[i], ",") && a[i][0] != '"' {
+			a[i] = fmt.Sprintf("%q", a[i])
+		}
+	}
+	return "[" + strings.Join(a, ", ") + "]"
+}
+
+// yamlQuote double-quotes s if it would not be read back as a plain scalar.
+func yamlQuote(s string) string {
+	switch {
+	case s == "", s == "~", strings.EqualFold(s, "null"),
+		strings.TrimSpace(s) != s,
+		strings.ContainsAny(s, ":#[]{},&*!|>'\"%@`\\\n\t"),
+		strings.HasPrefix(s, "- "), s == "-":
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// dotenvQuote quotes s as a dotenv value, if needed.
+func dotenvQuote(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,:/@+", r))
+	}) < 0 {
+		return s
+	}
+	if !strings.ContainsAny(s, "'\n") {
+		return "'" + s + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// shellQuote quotes s as a word for a POSIX shell, if needed.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,:/@+=%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,187 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestDump(t *testing.T) {
+	conf := struct {
+		Name     string        `flag:"name" env:"DUMP_NAME"`
+		Verbose  bool          `flag:"v" env:"DUMP_VERBOSE"`
+		Ratio    float64       `flag:"ratio"`
+		Timeout  time.Duration `flag:"timeout" env:"DUMP_TIMEOUT"`
+		Tags     []string      `flag:"tags" env:"DUMP_TAGS"`
+		Password string        `flag:"password" env:"DUMP_PASSWORD" secret:"true"`
+		Port     int           `flag:"db" env:"DUMP_PORT"`
+		DB       struct {
+			Host string `flag:"host" env:"HOST"`
+			Pool uint   `flag:"pool"`
+		} `flag:"db" env:"DUMP_DB"`
+	}{
+		Name:     "it's \"quoted\": # $HOME\n",
+		Verbose:  true,
+		Ratio:    0.25,
+		Timeout:  time.Minute,
+		Tags:     []string{"a b", "c"},
+		Password: "hunter2",
+		Port:     5432,
+	}
+	conf.DB.Host = "db.local"
+	conf.DB.Pool = 8
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.Struct(&conf)
+
+	tests := map[string]string{
+		"json": `{
+  "name": "it's \"quoted\": # $HOME\n",
+  "v": true,
+  "ratio": 0.25,
+  "timeout": "1m0s",
+  "tags": ["a b", "c"],
+  "db": 5432,
+  "db.host": "db.local",
+  "db.pool": 8
+}
+`,
+		"yaml": `name: "it's \"quoted\": # $HOME\n"
+v: true
+ratio: 0.25
+timeout: 1m0s
+tags: [a b, c]
+# password: <redacted>
+db: 5432
+db.host: db.local
+db.pool: 8
+`,
+		"env": `DUMP_NAME="it's \"quoted\": # \$HOME\n"
+DUMP_VERBOSE=true
+DUMP_TIMEOUT=1m0s
+DUMP_TAGS='a b,c'
+# DUMP_PASSWORD=<redacted>
+DUMP_PORT=5432
+DUMP_DB_HOST=db.local
+`,
+		"args": `'-name=it'\''s "quoted": # $HOME
+' -v=true -ratio=0.25 -timeout=1m0s '-tags=a b,c' -db=5432 -db.host=db.local -db.pool=8
+`,
+	}
+
+	for format, expected := range tests {
+		buf := bytes.Buffer{}
+		err := s.Dump(&buf, format)
+		if err != nil {
+			t.Errorf("Dump(%q) returned error %v", format, err)
+		}
+		if buf.String() != expected {
+			t.Errorf("Dump(%q) differs from expected.\nexpected:\n%s\nactual:\n%s", format, expected, buf.String())
+		}
+	}
+
+	err := s.Dump(&bytes.Buffer{}, "xml")
+	if err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestDumpNested(t *testing.T) {
+	conf := struct {
+		Name string `flag:"name"`
+		DB   struct {
+			Host     string `flag:"host"`
+			Password string `flag:"password" secret:"true"`
+		} `flag:"db"`
+	}{Name: "x"}
+	conf.DB.Host = "localhost"
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.Struct(&conf)
+
+	buf := bytes.Buffer{}
+	s.Dump(&buf, "yaml")
+	expected := "name: x\ndb:\n  host: localhost\n  # password: <redacted>\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	s.Dump(&buf, "json")
+	expected = "{\n  \"name\": \"x\",\n  \"db\": {\n    \"host\": \"localhost\"\n  }\n}\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	type config struct {
+		Name     string        `flag:"name" env:"DUMP_NAME"`
+		Verbose  bool          `flag:"v" env:"DUMP_VERBOSE"`
+		Ratio    float64       `flag:"ratio"`
+		Timeout  time.Duration `flag:"timeout" env:"DUMP_TIMEOUT"`
+		Tags     []string      `flag:"tags" env:"DUMP_TAGS"`
+		Password string        `flag:"password" env:"DUMP_PASSWORD" secret:"true"`
+		Port     int           `flag:"db" env:"DUMP_PORT"`
+		DB       struct {
+			Host string `flag:"host" env:"HOST"`
+			Pool uint   `flag:"pool"`
+		} `flag:"db" env:"DUMP_DB"`
+	}
+	dumped := config{
+		Name:     "it's \"quoted\": # $HOME\n",
+		Verbose:  true,
+		Ratio:    0.25,
+		Timeout:  time.Minute,
+		Tags:     []string{"a b", "c"},
+		Password: "hunter2",
+		Port:     5432,
+	}
+	dumped.DB.Host = "db.local"
+	dumped.DB.Pool = 8
+
+	for _, format := range []string{"json", "yaml", "env", "args"} {
+		original := dumped
+		s := NewFlagSet("program", flag.ContinueOnError)
+		s.Struct(&original)
+
+		buf := bytes.Buffer{}
+		s.Dump(&buf, format)
+
+		conf := &config{}
+		s = NewFlagSet("program", flag.ContinueOnError)
+		s.SetConfigFS(fstest.MapFS{"dump": {Data: buf.Bytes()}})
+		s.Struct(conf)
+
+		var err error
+		switch format {
+		case "env":
+			// The ratio and the pool only have flags.
+			conf.Ratio, conf.DB.Pool = 0.25, 8
+			err = s.LoadDotenv("/dump")
+			if err == nil {
+				err = s.ParseEnv()
+			}
+		case "args":
+			var args []string
+			args, err = splitWords(buf.String())
+			if err == nil {
+				err = s.Parse(args)
+			}
+		default:
+			err = s.LoadConfigFormat("/dump", format)
+		}
+		if err != nil {
+			t.Errorf("loading %s dump returned error %v", format, err)
+		}
+
+		expected := dumped
+		expected.Password = ""
+		if !reflect.DeepEqual(*conf, expected) {
+			t.Errorf("%s round trip differs.\nexpected: %+v\nactual:   %+v", format, expected, conf)
+		}
+	}
+}
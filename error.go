@@ -9,3 +9,11 @@ type unhandledTypeError struct {
 func (e unhandledTypeError) Error() string {
 	return fmt.Sprintf("unhandled flag type %t", e.typ)
 }
+
+type notStructError struct {
+	conf interface{}
+}
+
+func (e notStructError) Error() string {
+	return fmt.Sprintf("expected a pointer to a struct, got %T", e.conf)
+}
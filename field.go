@@ -0,0 +1,249 @@
+package flagstruct
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Origins recorded for fields that were not set from an environment variable.
+const (
+	originDefault = "default"
+	originFlag    = "flag"
+)
+
+// field holds the metadata of a struct member loaded by Struct.
+type field struct {
+	path       string
+	name       string
+	env        string
+	secret     bool
+	required   bool
+	oneof      []string
+	min        string
+	max        string
+	complete   string
+	hidden     bool
+	deprecated string
+	warned     bool
+	def        string
+	value      Value
+	origin     string
+	member     reflect.Value
+	initial    reflect.Value
+}
+
+// set sets the value of the field from s, checking its constraints.
+func (f *field) set(s string) error {
+	return f.update(func(v Value) error {
+		return v.Set(s)
+	})
+}
+
+// update calls fn with a copy of the value of the field, and assigns the copy
+// to the member if fn succeeds and the copy satisfies the constraints of the
+// field. The member is left unchanged on error.
+func (f *field) update(fn func(v Value) error) error {
+	p := reflect.New(f.member.Type())
+	p.Elem().Set(f.member)
+	v, err := valueFromPointer(p.Interface())
+	if err != nil {
+		return err
+	}
+
+	err = fn(v)
+	if err == nil {
+		err = f.check(v)
+	}
+	if err == nil {
+		f.member.Set(p.Elem())
+	}
+	return err
+}
+
+// fieldValue wraps a field's Value when registering it as a flag, so the
+// origin of the value is known after parsing.
+type fieldValue struct {
+	Value
+	field *field
+	set   *FlagSet
+}
+
+// Set implements the Value interface.
+func (v *fieldValue) Set(s string) error {
+	err := v.field.set(s)
+	if err == nil {
+		setting := "flag -" + v.field.name
+		if v.set.source != originFlag {
+			setting += " in " + v.set.source
+		}
+		v.set.setOrigin(v.field, v.set.source, setting)
+	}
+	return err
+}
+
+// invalidValue returns an error reporting that the value of f read from
+// source is invalid. As parse errors may quote the value, only the type
+// expected is reported if the value was read from a file, which may hold
+// secrets, or if f is secret.
+func (f *field) invalidValue(source string, file bool, err error) error {
+	if file || f.secret {
+		return fmt.Errorf("%s: invalid value, expected %s", source, f.member.Type())
+	}
+	return fmt.Errorf("%s: invalid value: %v", source, err)
+}
+
+// setOrigin records where the value of f was set from. The first time a
+// deprecated member is set, a warning naming the setting, such as
+// "flag -name" or "key name in app.yaml", is printed.
+func (s *FlagSet) setOrigin(f *field, origin, setting string) {
+	f.origin = origin
+	if f.deprecated == "" || f.warned {
+		return
+	}
+	f.warned = true
+	fmt.Fprintf(s.out(), "%s is deprecated: %s\n", setting, f.deprecated)
+}
+
+// IsBoolFlag signals boolean flag behavior to Go's flag library.
+func (v *fieldValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Origin returns where the value of the struct member named path was last set
+// from: "default", "flag", "$KEY" for environment variable KEY, or the path of
+// the configuration, dotenv or secret file it was read from. An empty string is
+// returned for unknown members.
+func (s *FlagSet) Origin(path string) string {
+	for _, f := range s.fields {
+		if f.path == path {
+			return f.origin
+		}
+	}
+	return ""
+}
+
+// structField is a struct member visited by walkStruct.
+type structField struct {
+	reflect.StructField
+	value  reflect.Value
+	parent *structField
+	path   string
+	name   string
+	env    string
+	naming Naming
+}
+
+// tag returns the value of the struct tag key of the member, or of the
+// nearest nested struct containing it that has the tag.
+func (sf *structField) tag(key string) string {
+	for ; sf != nil; sf = sf.parent {
+		if v, ok := sf.Tag.Lookup(key); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// walkStruct calls fn for each exported member of the struct pointed to by
+// conf, and for "_" separators. Nested structs that do not implement Value are
+// descended into; their flag and env tags, if any, prefix the flag names and
+// environment keys of their members, joined by "." and "_" respectively.
+// An error is returned if conf is not a non-nil pointer to a struct.
+func walkStruct(conf interface{}, fn func(sf structField) error) error {
+	return walkRoot(conf, &structField{}, fn)
+}
+
+// walkRoot is like walkStruct, with the members of the struct pointed to by
+// conf in root, which prefixes their paths, flag names and environment keys,
+// and names untagged members with its naming, if any.
+func walkRoot(conf interface{}, root *structField, fn func(sf structField) error) error {
+	v := reflect.ValueOf(conf)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return notStructError{conf}
+	}
+	return walkValue(v.Elem(), root, fn)
+}
+
+func walkValue(v reflect.Value, parent *structField, fn func(sf structField) error) error {
+	t := v.Type()
+
+	for i, l := 0, t.NumField(); i < l; i++ {
+		ft, fv := t.Field(i), v.Field(i)
+		sf := structField{StructField: ft, value: fv, path: ft.Name, naming: parent.naming}
+		if parent.path != "" {
+			sf.path = parent.path + "." + ft.Name
+			sf.parent = parent
+		}
+
+		// _ can be used to separate sections.
+		if ft.Name == "_" {
+			if err := fn(sf); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Skip unexported fields.
+		if ft.PkgPath != "" {
+			continue
+		}
+
+		key, hasKey := ft.Tag.Lookup("env")
+		name, hasName := ft.Tag.Lookup("flag")
+		if parent.naming != nil && (!hasKey || !hasName) {
+			n, k := parent.naming(ft.Name)
+			if !hasName {
+				name = n
+			}
+			if !hasKey {
+				key = k
+			}
+		}
+		if key == "-" {
+			key = ""
+		}
+		if name == "-" {
+			name = ""
+		}
+
+		if isNested(fv) {
+			// Nested structs carry the prefixes of their members.
+			sf.name, sf.env = join(parent.name, name, "."), join(parent.env, key, "_")
+			if err := walkValue(fv, &sf, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if key != "" {
+			sf.env = join(parent.env, key, "_")
+		}
+		if name != "" {
+			sf.name = join(parent.name, name, ".")
+		}
+
+		if err := fn(sf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// join joins a prefix and a name with sep, if both are non-empty.
+func join(prefix, name, sep string) string {
+	if prefix == "" || name == "" {
+		return prefix + name
+	}
+	return prefix + sep + name
+}
+
+// isNested reports whether v is a struct to be descended into.
+func isNested(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	_, ok := v.Addr().Interface().(Value)
+	return !ok
+}
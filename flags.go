@@ -1,34 +1,99 @@
 // Package flagstruct is a simple package that allows you to express flag and
 // environment variable based configuration using structs and struct tagging.
 //
-// Structures
+// # Structures
 //
 // flagstruct works on arbitrary structures with struct tagging. The following
 // struct tags are supported:
 //
-//  - "flag": Maps the struct member to a command line flag.
-//  - "env": Maps the struct member to an environment variable.
-//  - "usage": Specifies the usage string to use for the flag.
+//   - "flag": Maps the struct member to a command line flag.
+//   - "env": Maps the struct member to an environment variable.
+//   - "usage": Specifies the usage string to use for the flag.
+//   - "default": Sets the default value of the member, parsed as a flag value,
+//     if it is zero. See SetDefaultPolicy for members that are not.
+//   - "secret": If "true", the value of the member is redacted from output.
+//   - "required": If "true", the member must have a non-zero value once
+//     Configure has parsed all sources.
+//   - "oneof": Lists the values allowed for the member, separated by spaces.
+//   - "min", "max": Bound the value of a number or duration, the length of a
+//     string or the number of elements of a slice.
 //
-// Default values are derived from the value of the member in the struct. To
-// see exactly how this works, check out the package example.
+// The "required", "oneof", "min" and "max" constraints are enforced: values
+// set from any source that break them are rejected, with the member left
+// unchanged, and Configure fails if a required member is zero. They are also
+// listed in usage output, documentation, sample files and JSON Schemas.
+//   - "complete": Completes the value of the flag with "file" or "dir" names
+//     in shell completion scripts.
+//   - "group": Titles the section started by a "_" separator or formed by a
+//     nested struct in usage output. The "usage" tag of a separator titles its
+//     section as well, if it has no group tag.
+//   - "description": Describes the section titled by a "group" tag.
+//   - "hidden": If "true", the flag is parsed but left out of usage output,
+//     documentation and completion scripts.
+//   - "deprecated": Marks the member as deprecated, with a note such as
+//     "use -new-name". A warning is printed the first time it is set, and
+//     the flag is only listed, with the note, in verbose help.
+//   - "reload": Sets whether the member may change when a Reloader reloads
+//     the configuration: "dynamic" (the default) or "static".
+//
+// Default values are derived from the value of the member in the struct, or
+// its "default" tag. To see exactly how this works, check out the package
+// example.
+//
+// Nested structs are descended into. Their "flag" and "env" tags, if any,
+// prefix the names of their members, joined by "." and "_" respectively.
+// Members without these tags have no flag or environment key, unless a Naming
+// set with SetNaming derives them from their field names.
+// Slices of the supported types take comma-separated values.
+//
+// Several structs may be loaded into a flag set, in namespaces prefixing their
+// flags and environment keys. Packages may register their own with Register,
+// to be loaded by Configure.
+//
+// # Configuration Files
+//
+// Configuration files in JSON, YAML or TOML format map flag names to values,
+// with nested tables for nested structs and arrays for slices. They can be
+// loaded explicitly with LoadConfig, which also reads JSON with comments, or
+// discovered with SetConfigPaths. WriteSample generates an annotated sample
+// file from a struct, and WriteJSONSchema a JSON Schema to validate them.
 package flagstruct
 
 import (
 	"flag"
+	"io"
 	"os"
 )
 
 var exit = os.Exit
 
+var stdout io.Writer = os.Stdout
+
 // CommandLine is the default set of command-line flags, parsed from os.Args.
-var CommandLine = &FlagSet{flag.CommandLine, os.Args[0], flag.ExitOnError, nil, map[string]Value{}}
+var CommandLine = newFlagSet(flag.CommandLine, os.Args[0], flag.ExitOnError)
 
 // Struct loads parameters based off of a struct object.
 func Struct(conf interface{}) error {
 	return CommandLine.Struct(conf)
 }
 
+// StructNamespace loads parameters based off of a struct object, in a
+// namespace.
+func StructNamespace(namespace string, conf interface{}) error {
+	return CommandLine.StructNamespace(namespace, conf)
+}
+
+// Register registers a struct to be loaded by Configure in a namespace.
+func Register(namespace string, conf interface{}) {
+	CommandLine.Register(namespace, conf)
+}
+
+// SetDefault sets the default value of the member with the flag name, path or
+// environment key name, overriding its value when Configure loads it.
+func SetDefault(name, value string) {
+	CommandLine.SetDefault(name, value)
+}
+
 // Parse parses the command line parameters from argv.
 func Parse() error {
 	return CommandLine.Parse(os.Args[1:])
@@ -45,7 +110,10 @@ func PrintStruct(conf interface{}) {
 }
 
 // Configure sets up enhanced usage help, loads a structure, parses environment
-// and parses flags.
-func Configure(conf interface{}) error {
-	return CommandLine.Configure(conf, os.Args[1:])
+// and parses flags. Options customizing the flag set are applied to
+// CommandLine, and WithArgs replaces os.Args[1:].
+func Configure(conf interface{}, opts ...Option) error {
+	o := newOptions(opts)
+	o.apply(CommandLine)
+	return CommandLine.Configure(conf, o.args)
 }
@@ -1,33 +1,113 @@
 package flagstruct
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"reflect"
+	"text/template"
 )
 
 // A FlagSet represents a set of defined flags.
 type FlagSet struct {
 	*flag.FlagSet
-	name          string
-	errorHandling flag.ErrorHandling
-	output        io.Writer
-	env           map[string]Value
+	name           string
+	errorHandling  flag.ErrorHandling
+	output         io.Writer
+	fields         []*field
+	structs        []loadedStruct
+	registered     []loadedStruct
+	overrides      []override
+	applied        []override
+	env            map[string]*field
+	argsEnv        string
+	lookupEnvFunc  func(key string) (string, bool)
+	source         string
+	configFS       fs.FS
+	configPaths    []string
+	configFiles    []string
+	dotenv         map[string]dotenvValue
+	dotenvPaths    []string
+	dotenvOverride bool
+	fileEnv        bool
+	secretDirs     []string
+	keyDirs        []string
+	keyDirSep      string
+	helpWidth      int
+	helpEnv        bool
+	helpSort       bool
+	helpLive       bool
+	helpVerbose    bool
+	docOtherFlags  bool
+	defaultPolicy  DefaultPolicy
+	usageTemplate  *template.Template
+	aliases        map[string]string
+	ownFlags       []string
+	argsUsage      string
+	subcommands    []UsageCommand
+	envPrefix      string
+	flagPrefix     string
+	naming         Naming
 }
 
 // NewFlagSet returns a new, empty flag set with the specified name and error
-// handling property.
-func NewFlagSet(name string, errorHandling flag.ErrorHandling) *FlagSet {
-	return &FlagSet{flag.NewFlagSet(name, errorHandling), name, errorHandling, nil, map[string]Value{}}
+// handling property, customized by opts.
+func NewFlagSet(name string, errorHandling flag.ErrorHandling, opts ...Option) *FlagSet {
+	s := newFlagSet(flag.NewFlagSet(name, errorHandling), name, errorHandling)
+	newOptions(opts).apply(s)
+	return s
 }
 
-// Configure sets up enhanced usage help, loads a structure, parses environment
-// and parses flags.
+func newFlagSet(fs *flag.FlagSet, name string, errorHandling flag.ErrorHandling) *FlagSet {
+	return &FlagSet{
+		FlagSet:       fs,
+		name:          name,
+		errorHandling: errorHandling,
+		env:           map[string]*field{},
+		lookupEnvFunc: os.LookupEnv,
+		source:        originFlag,
+	}
+}
+
+// Configure sets up enhanced usage help, loads a structure, parses
+// configuration files, parses environment and parses flags. Members tagged
+// required must have a non-zero value afterwards.
+//
+// The structs registered with Register are loaded after conf, which may be
+// nil, and the defaults set with SetDefault are applied before parsing. The
+// usage function is made again, to list the flags defined so far.
+//
+// If the first argument is the hidden __complete argument used by completion
+// scripts, Configure writes the completions of the flag value that follows to
+// standard output, then exits if the error handling property of the set is
+// ExitOnError, or returns ErrComplete otherwise.
 func (s *FlagSet) Configure(conf interface{}, arguments []string) error {
-	err := s.Struct(conf)
+	if conf != nil {
+		err := s.Struct(conf)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.loadRegistered()
+	if err != nil {
+		return err
+	}
+	if len(s.structs) > 0 {
+		s.Usage = s.makeStructUsage(s.structGroups)
+	}
+
+	if len(arguments) > 0 && arguments[0] == completeArg {
+		s.complete(arguments[1:])
+		if s.errorHandling == flag.ExitOnError {
+			exit(0)
+		}
+		return ErrComplete
+	}
+
+	err = s.ParseConfig()
 	if err != nil {
 		return err
 	}
@@ -37,41 +117,81 @@ func (s *FlagSet) Configure(conf interface{}, arguments []string) error {
 		return err
 	}
 
+	err = s.ParseArgsEnv()
+	if err != nil {
+		return err
+	}
+
 	err = s.Parse(arguments)
 	if err != nil {
 		return err
 	}
 
+	return s.checkRequired()
+}
+
+// checkRequired returns an error naming the first member tagged required that
+// is zero after parsing.
+func (s *FlagSet) checkRequired() error {
+	for _, f := range s.fields {
+		if !f.required || !f.member.IsZero() {
+			continue
+		}
+		switch {
+		case f.name != "":
+			return s.failf("required flag -%s not set", f.name)
+		case f.env != "":
+			return s.failf("required environment variable $%s not set", f.env)
+		default:
+			return s.failf("required member %s not set", f.path)
+		}
+	}
 	return nil
 }
 
-// MakeStructUsage creates a usage function from a struct.
+// MakeStructUsage creates a usage function from a struct. Flags not loaded
+// from structs, such as those defined with the flag package, are listed after
+// it, under "Other flags", except those defined by the flag set itself, such
+// as with HelpLiveFlag. In live help, see SetHelpLive, the usage reflects
+// the struct when the function is called.
 func (s *FlagSet) MakeStructUsage(conf interface{}) func() {
-	// Cache struct usage (otherwise default values change)
-	buf, oldout := bytes.Buffer{}, s.output
-	s.output = &buf
-	s.PrintStruct(conf)
-	s.output = oldout
+	return s.makeStructUsage(func() []*helpGroup {
+		return s.helpGroups(conf)
+	})
+}
+
+// makeStructUsage creates a usage function printing the sections returned by
+// groups, with the flags defined by the flag set itself in the first one,
+// followed by the flags not loaded from structs, if any.
+func (s *FlagSet) makeStructUsage(structGroups func() []*helpGroup) func() {
+	groups := func() []*helpGroup {
+		groups := structGroups()
+		if own := s.ownEntries(); len(own) > 0 {
+			if len(groups) == 0 || groups[0].title != "" {
+				groups = append([]*helpGroup{{}}, groups...)
+			}
+			groups[0].entries = append(groups[0].entries, own...)
+		}
+		if g := s.otherGroup(); g != nil {
+			groups = append(groups, g)
+		}
+		return groups
+	}
 
+	// Cache struct usage (otherwise default values change)
+	t, data := s.usageTemplate, s.usageData(groups())
 	return func() {
-		if s.name == "" {
-			fmt.Fprintf(s.out(), "Usage:\n")
-		} else {
-			fmt.Fprintf(s.out(), "Usage of %s:\n", s.name)
+		if s.helpLive {
+			data = s.usageData(groups())
 		}
-		fmt.Fprint(s.out(), buf.String())
+		s.executeUsage(t, data)
 	}
 }
 
 // MakeUsage creates a usage function that prints the flags.
 func (s *FlagSet) MakeUsage() func() {
 	return func() {
-		if s.name == "" {
-			fmt.Fprintf(s.out(), "Usage:\n")
-		} else {
-			fmt.Fprintf(s.out(), "Usage of %s:\n", s.name)
-		}
-		s.PrintDefaults()
+		s.executeUsage(s.usageTemplate, s.usageData(s.flagGroups()))
 	}
 }
 
@@ -82,6 +202,15 @@ func (s *FlagSet) out() io.Writer {
 	return s.output
 }
 
+// SetArgsEnv sets the name of an environment variable holding additional
+// command-line arguments, such as MYAPP_FLAGS. The variable is split into words
+// following POSIX shell quoting rules and parsed by ParseArgsEnv, which lets
+// it override individual environment variables, but not the actual arguments.
+// An empty key disables the feature, which is the default.
+func (s *FlagSet) SetArgsEnv(key string) {
+	s.argsEnv = key
+}
+
 // SetOutput sets the destination for usage and error messages.
 // If output is nil, os.Stderr is used.
 func (s *FlagSet) SetOutput(output io.Writer) {
@@ -89,76 +218,204 @@ func (s *FlagSet) SetOutput(output io.Writer) {
 	s.FlagSet.SetOutput(output)
 }
 
-// Struct loads parameters based off of a struct object.
+// loadedStruct is a struct loaded by Struct or StructNamespace, with its value
+// before it was loaded, or registered with Register.
+type loadedStruct struct {
+	namespace string
+	conf      interface{}
+	initial   reflect.Value
+}
+
+// Struct loads parameters based off of a struct object. Members of nested
+// structs are loaded as well, see walkStruct. Their defaults are recorded, so
+// Reset can restore them. Struct may be called several times, see
+// StructNamespace.
 func (s *FlagSet) Struct(conf interface{}) error {
-	var err error
+	return s.StructNamespace("", conf)
+}
 
-	v := reflect.ValueOf(conf).Elem()
-	t := reflect.TypeOf(conf).Elem()
+// StructNamespace loads parameters from a struct object like Struct, in a
+// namespace. Unless it is empty, the namespace prefixes the flag names of the
+// members, joined by ".", their environment keys, in upper case and joined by
+// "_", and their paths. For example, the member Host tagged flag:"host" and
+// env:"HOST" in the namespace "db" has the flag -db.host, the environment key
+// DB_HOST and the path "db.Host".
+//
+// The structs loaded into a flag set are listed together in usage output, in
+// order, each namespace in a section titled by it. Flag names and environment
+// keys already defined are reported as errors.
+func (s *FlagSet) StructNamespace(namespace string, conf interface{}) error {
+	var initial reflect.Value
+	err := s.checkConflicts(namespace, conf)
+	if err == nil {
+		initial = copyValue(reflect.ValueOf(conf).Elem())
+		err = s.loadStruct(namespace, conf)
+	}
+	if err != nil {
+		if s.errorHandling == flag.ContinueOnError {
+			return err
+		}
 
-	for i, l := 0, t.NumField(); i < l; i++ {
-		var key, name, usage string
-		var addr interface{}
-		var val Value
+		// Panic even on exit-on-error case; do not swallow error.
+		panic(err)
+	}
 
-		ft, fv := t.Field(i), v.Field(i)
+	s.structs = append(s.structs, loadedStruct{namespace, conf, initial})
+	s.Usage = s.makeStructUsage(s.structGroups)
 
-		// Skip unexported fields.
-		if ft.PkgPath != "" {
-			continue
-		}
+	return nil
+}
 
-		// Handle 'env' flag.
-		key = ft.Tag.Get("env")
-		if key != "" && key != "-" {
-			addr = fv.Addr().Interface()
-			s.env[key], err = valueFromPointer(addr)
-			if err != nil {
-				goto HandleErr
+// checkConflicts returns an error if a flag name or environment key of the
+// struct passed to conf is already defined.
+func (s *FlagSet) checkConflicts(namespace string, conf interface{}) error {
+	names, keys := map[string]string{}, map[string]string{}
+	return s.walkNamespace(conf, namespace, func(sf structField) error {
+		if sf.name != "" {
+			if s.Lookup(sf.name) != nil {
+				return fmt.Errorf("flag -%s of %s is already defined", sf.name, sf.path)
+			}
+			if p, ok := names[sf.name]; ok {
+				return fmt.Errorf("flag -%s of %s is already defined by %s", sf.name, sf.path, p)
 			}
+			names[sf.name] = sf.path
 		}
+		if sf.env != "" {
+			if f, ok := s.env[sf.env]; ok {
+				return fmt.Errorf("environment key %s of %s is already used by %s", sf.env, sf.path, f.path)
+			}
+			if p, ok := keys[sf.env]; ok {
+				return fmt.Errorf("environment key %s of %s is already used by %s", sf.env, sf.path, p)
+			}
+			keys[sf.env] = sf.path
+		}
+		return nil
+	})
+}
 
-		name, usage = ft.Tag.Get("flag"), ft.Tag.Get("usage")
-		if name == "" || name == "-" {
-			continue
+// loadStruct loads the members of the struct passed to conf in a namespace.
+func (s *FlagSet) loadStruct(namespace string, conf interface{}) error {
+	return s.walkNamespace(conf, namespace, func(sf structField) error {
+		if sf.name == "" && sf.env == "" {
+			return nil
 		}
 
-		addr = fv.Addr().Interface()
+		switch policy := sf.tag("reload"); policy {
+		case "", reloadStatic, reloadDynamic:
+		default:
+			return fmt.Errorf("invalid reload policy %q for %s", policy, sf.path)
+		}
+
+		switch hint := sf.Tag.Get("complete"); hint {
+		case "", completeFile, completeDir:
+		default:
+			return fmt.Errorf("invalid completion hint %q for %s", hint, sf.path)
+		}
 
 		// Get Value from pointer.
-		val, err = valueFromPointer(addr)
+		val, err := valueFromPointer(sf.value.Addr().Interface())
+		if err != nil {
+			return err
+		}
 
-	HandleErr:
+		f := &field{
+			path:       sf.path,
+			name:       sf.name,
+			env:        sf.env,
+			secret:     sf.tag("secret") == "true",
+			required:   sf.Tag.Get("required") == "true",
+			complete:   sf.Tag.Get("complete"),
+			hidden:     sf.tag("hidden") == "true",
+			deprecated: sf.Tag.Get("deprecated"),
+			value:      val,
+			member:     sf.value,
+			origin:     originDefault,
+		}
+		err = f.parseConstraints(sf)
 		if err != nil {
-			if s.errorHandling == flag.ContinueOnError {
-				return err
-			}
+			return err
+		}
+		err = s.applyDefault(f, sf)
+		if err != nil {
+			return err
+		}
+		f.initial = copyValue(sf.value)
+		_, _, f.def = describe(sf)
+		s.fields = append(s.fields, f)
 
-			// Panic even on exit-on-error case; do not swallow error.
-			panic(err)
+		// Handle 'env' flag.
+		if sf.env != "" {
+			s.env[sf.env] = f
+		}
+
+		if sf.name != "" {
+			s.Var(&fieldValue{val, f, s}, sf.name, sf.Tag.Get("usage"))
 		}
 
-		s.Var(val.(Value), name, usage)
+		return nil
+	})
+}
+
+// namespaceOf returns the namespace the struct passed to conf was loaded in,
+// if any.
+func (s *FlagSet) namespaceOf(conf interface{}) string {
+	for _, l := range s.structs {
+		if l.conf == conf {
+			return l.namespace
+		}
 	}
+	return ""
+}
 
-	s.Usage = s.MakeStructUsage(conf)
+// walk calls walkNamespace for the struct passed to conf, in the namespace it
+// was loaded in.
+func (s *FlagSet) walk(conf interface{}, fn func(sf structField) error) error {
+	return s.walkNamespace(conf, s.namespaceOf(conf), fn)
+}
 
-	return nil
+// walkNamespace calls walkRoot for the struct passed to conf in a namespace,
+// which prefixes the paths and flag names of the members, and their
+// environment keys in upper case, after the prefixes of the flag set. Untagged
+// members are named by the naming of the flag set, if any.
+func (s *FlagSet) walkNamespace(conf interface{}, namespace string, fn func(sf structField) error) error {
+	root := &structField{
+		path:   namespace,
+		name:   join(s.flagPrefix, namespace, "."),
+		env:    join(s.envPrefix, envKey(namespace), "_"),
+		naming: s.naming,
+	}
+	return walkRoot(conf, root, fn)
 }
 
-// ParseEnv parses environment variables.
+// ParseEnv parses environment variables, including those loaded from dotenv
+// files, and secrets read from files if enabled.
 func (s *FlagSet) ParseEnv() error {
 	var err error
 
-	for key, val := range s.env {
-		v, ok := os.LookupEnv(key)
+	for key, f := range s.env {
+		var v, origin string
+		var ok bool
+		v, origin, ok, err = s.envValue(key)
+		if err != nil {
+			break
+		}
 		if !ok {
 			continue
 		}
-		err = val.Set(v)
+		source, file := "$"+key, origin != "$"+key
+		if file {
+			source = fmt.Sprintf("$%s in %s", key, origin)
+		}
+		err = f.set(v)
 		if err != nil {
+			err = f.invalidValue(source, file, err)
 			break
 		}
+		setting := "environment variable $" + key
+		if file {
+			setting = source
+		}
+		s.setOrigin(f, origin, setting)
 	}
 
 	if err != nil {
@@ -172,3 +429,48 @@ func (s *FlagSet) ParseEnv() error {
 
 	return err
 }
+
+// ParseArgsEnv parses the flags held in the environment variable set with
+// SetArgsEnv, if any. Positional arguments are not allowed in the variable.
+func (s *FlagSet) ParseArgsEnv() error {
+	if s.argsEnv == "" {
+		return nil
+	}
+
+	v, _, ok := s.lookupEnv(s.argsEnv)
+	if !ok {
+		return nil
+	}
+
+	args, err := splitWords(v)
+	if err != nil {
+		return s.failf("$%s: %v", s.argsEnv, err)
+	}
+
+	s.source = "$" + s.argsEnv
+	err = s.FlagSet.Parse(args)
+	s.source = originFlag
+	if err != nil {
+		return fmt.Errorf("$%s: %w", s.argsEnv, err)
+	}
+
+	if s.NArg() > 0 {
+		return s.failf("$%s: unexpected argument %q", s.argsEnv, s.Arg(0))
+	}
+
+	return nil
+}
+
+// failf formats an error and handles it according to the error handling
+// property of the set.
+func (s *FlagSet) failf(format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	fmt.Fprintln(s.out(), err)
+	switch s.errorHandling {
+	case flag.ExitOnError:
+		exit(2)
+	case flag.PanicOnError:
+		panic(err)
+	}
+	return err
+}
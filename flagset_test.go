@@ -2,12 +2,15 @@ package flagstruct
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"text/template"
 	"time"
 )
 
@@ -273,7 +276,7 @@ func TestBadEnvParse(t *testing.T) {
 		if r == nil {
 			t.Error("expected panic did not occur")
 		}
-		if r.(error).Error() != `strconv.ParseBool: parsing "Invalid": invalid syntax` {
+		if r.(error).Error() != `$ENV_TEST: invalid value: strconv.ParseBool: parsing "Invalid": invalid syntax` {
 			t.Error("wrong error", r.(error).Error())
 		}
 	}()
@@ -339,3 +342,361 @@ func TestStructUsage(t *testing.T) {
 		t.Errorf("usage output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expectedp, buf.String())
 	}
 }
+
+func TestArgsEnv(t *testing.T) {
+	conf := struct {
+		Name    string `flag:"name" env:"ARGS_ENV_NAME"`
+		Level   int    `flag:"level" env:"ARGS_ENV_LEVEL"`
+		Verbose bool   `flag:"v"`
+		Other   int    `flag:"other"`
+	}{}
+
+	os.Setenv("ARGS_ENV_NAME", "env")
+	os.Setenv("ARGS_ENV_LEVEL", "1")
+	os.Setenv("ARGS_ENV_FLAGS", `-name "from flags" -level=2 -v`)
+	defer os.Unsetenv("ARGS_ENV_NAME")
+	defer os.Unsetenv("ARGS_ENV_LEVEL")
+	defer os.Unsetenv("ARGS_ENV_FLAGS")
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetArgsEnv("ARGS_ENV_FLAGS")
+	err := s.Configure(&conf, []string{"-level=3", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Name != "from flags" || conf.Level != 3 || !conf.Verbose {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	if s.NArg() != 1 || s.Arg(0) != "arg" {
+		t.Errorf("unexpected arguments %q", s.Args())
+	}
+
+	origins := map[string]string{
+		"Name":    "$ARGS_ENV_FLAGS",
+		"Level":   "flag",
+		"Verbose": "$ARGS_ENV_FLAGS",
+		"Other":   "default",
+		"Missing": "",
+	}
+	for path, expected := range origins {
+		if origin := s.Origin(path); origin != expected {
+			t.Errorf("Origin(%q) = %q, expected %q", path, origin, expected)
+		}
+	}
+
+	for _, v := range []string{`-name 'x`, "-v arg", "-undefined"} {
+		os.Setenv("ARGS_ENV_FLAGS", v)
+		s = NewFlagSet("program", flag.ContinueOnError)
+		s.SetOutput(&bytes.Buffer{})
+		s.SetArgsEnv("ARGS_ENV_FLAGS")
+		err = s.Configure(&conf, []string{})
+		if err == nil {
+			t.Errorf("expected error for %q", v)
+		}
+	}
+
+	os.Setenv("ARGS_ENV_FLAGS", "-help")
+	s = NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&bytes.Buffer{})
+	s.SetArgsEnv("ARGS_ENV_FLAGS")
+	err = s.Configure(&conf, []string{})
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNestedStruct(t *testing.T) {
+	type dbConfig struct {
+		Host string `flag:"host" env:"HOST" usage:"database host"`
+		Port int    `flag:"port"`
+	}
+	conf := struct {
+		Name    string   `flag:"name"`
+		DB      dbConfig `flag:"db" env:"NESTED_DB"`
+		Replica dbConfig `env:"NESTED_REPLICA"`
+	}{DB: dbConfig{Port: 5432}}
+
+	os.Setenv("NESTED_DB_HOST", "env")
+	defer os.Unsetenv("NESTED_DB_HOST")
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&buf)
+	err := s.Configure(&conf, []string{"-port=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.DB.Host != "env" || conf.DB.Port != 5432 || conf.Replica.Port != 1 {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	if origin := s.Origin("DB.Host"); origin != "$NESTED_DB_HOST" {
+		t.Errorf("unexpected origin %q", origin)
+	}
+
+	s.PrintStruct(&conf)
+	expectedp := "" +
+		"  -name string\n    \t\n" +
+		"  -db.host string\n    \tdatabase host (default \"env\")\n" +
+		"  -db.port int\n    \t (default 5432)\n" +
+		"  -host string\n    \tdatabase host\n" +
+		"  -port int\n    \t (default 1)\n"
+	if buf.String() != expectedp {
+		t.Errorf("print output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expectedp, buf.String())
+	}
+}
+
+func TestStructGroups(t *testing.T) {
+	type dbConfig struct {
+		Port int    `flag:"port" env:"PORT"`
+		Host string `flag:"host" env:"HOST" usage:"database host, resolved through DNS when the connection is opened"`
+	}
+	conf := struct {
+		Verbose bool     `flag:"v" usage:"verbose output"`
+		Name    string   `flag:"name" env:"GROUPS_NAME" usage:"~name~ of the service"`
+		_       struct{} `group:"Limits" description:"Limits applied to each request."`
+		Timeout int      `flag:"timeout" usage:"timeout in seconds"`
+		DB      dbConfig `flag:"db" env:"GROUPS_DB" group:"Database"`
+		Debug   bool     `flag:"debug"`
+	}{Name: "api", DB: dbConfig{Port: 5432}}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&buf)
+	s.PrintStruct(&conf)
+	expectedp := "" +
+		"  -v\tverbose output\n" +
+		"  -name name\n    \tname of the service (default \"api\")\n" +
+		"\nLimits:\n  Limits applied to each request.\n" +
+		"  -timeout int\n    \ttimeout in seconds\n" +
+		"\nDatabase:\n" +
+		"  -db.port int\n    \t (default 5432)\n" +
+		"  -db.host string\n    \tdatabase host, resolved through DNS when the connection is opened\n" +
+		"\n  -debug\n    \t\n"
+	if buf.String() != expectedp {
+		t.Errorf("print output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expectedp, buf.String())
+	}
+
+	buf.Reset()
+	s.SetHelpWidth(60)
+	s.SetHelpEnv(true)
+	s.SetHelpSort(true)
+	s.PrintStruct(&conf)
+	expectedp = "" +
+		"  -name name ($GROUPS_NAME)       name of the service\n" +
+		"                                  (default \"api\")\n" +
+		"  -v                              verbose output\n" +
+		"\nLimits:\n  Limits applied to each request.\n" +
+		"  -timeout int                    timeout in seconds\n" +
+		"\nDatabase:\n" +
+		"  -db.host string ($GROUPS_DB_HOST)\n" +
+		"                                  database host, resolved\n" +
+		"                                  through DNS when the\n" +
+		"                                  connection is opened\n" +
+		"  -db.port int ($GROUPS_DB_PORT)  (default 5432)\n" +
+		"\n  -debug\n"
+	if buf.String() != expectedp {
+		t.Errorf("print output differs from expected.\nexpected:\n%s\nactual:\n%s\n", expectedp, buf.String())
+	}
+}
+
+func TestHelpLive(t *testing.T) {
+	conf := struct {
+		Name  string `flag:"name" env:"LIVE_NAME" usage:"service name"`
+		Port  int    `flag:"port"`
+		Debug bool   `flag:"debug"`
+		Token string `flag:"token" secret:"true"`
+	}{Name: "api", Port: 1, Token: "t0k"}
+
+	os.Setenv("LIVE_NAME", "prod")
+	defer os.Unsetenv("LIVE_NAME")
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&buf)
+	s.HelpLiveFlag("print-config", "show current values in help")
+	err := s.Configure(&conf, []string{"-port=2", "-token=x", "-print-config", "-help"})
+	if err != flag.ErrHelp {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := "Usage of program:\n" +
+		"  -name string\n    \tservice name (default \"api\") (value \"prod\" from $LIVE_NAME)\n" +
+		"  -port int\n    \t (default 1) (value 2 from flag)\n" +
+		"  -debug\n    \t (value false from default)\n" +
+		"  -token string\n    \t (default <redacted>) (value <redacted> from flag)\n" +
+		"  -print-config\n    \tshow current values in help\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expected, buf.String())
+	}
+
+	buf.Reset()
+	s.SetUsageTemplate(template.Must(template.New("usage").Parse(DefaultUsageTemplate)))
+	s.MakeStructUsage(&conf)()
+	if buf.String() != expected {
+		t.Errorf("template output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expected, buf.String())
+	}
+}
+
+func TestHiddenDeprecated(t *testing.T) {
+	conf := struct {
+		Name  string `flag:"name" usage:"service name"`
+		Old   string `flag:"old-name" env:"OLD_NAME" usage:"service name" deprecated:"use -name"`
+		Trace bool   `flag:"trace" hidden:"true"`
+	}{}
+
+	os.Setenv("OLD_NAME", "env")
+	defer os.Unsetenv("OLD_NAME")
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&buf)
+	err := s.Configure(&conf, []string{"-trace", "-old-name=a", "-old-name=b"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !conf.Trace || conf.Old != "b" {
+		t.Errorf("hidden and deprecated flags not parsed: %+v", conf)
+	}
+
+	expected := "environment variable $OLD_NAME is deprecated: use -name\n"
+	if buf.String() != expected {
+		t.Errorf("warning differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	s.Usage()
+	expected = "Usage of program:\n" +
+		"  -name string\n    \tservice name\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	s.SetHelpVerbose(true)
+	s.PrintDefaults()
+	expected = "  -name string\n    \tservice name\n" +
+		"  -old-name string\n    \tservice name (deprecated: use -name)\n"
+	if buf.String() != expected {
+		t.Errorf("verbose output differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+	}
+
+	comps := s.completions()
+	if len(comps) != 1 || comps[0].name != "name" {
+		t.Errorf("unexpected completions %+v", comps)
+	}
+
+	// Warnings name where deprecated members are set.
+	for _, source := range []string{"config", "keydir"} {
+		buf.Reset()
+		s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+		s.SetConfigFS(fstest.MapFS{
+			"conf.json":     {Data: []byte(`{"old-name": "c"}`)},
+			"keys/old-name": {Data: []byte("k\n")},
+		})
+		s.Struct(&conf)
+		if source == "config" {
+			err = s.LoadConfig("/conf.json")
+			expected = "key old-name in /conf.json is deprecated: use -name\n"
+		} else {
+			err = s.LoadKeyDir("/keys")
+			expected = "key file /keys/old-name is deprecated: use -name\n"
+		}
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if buf.String() != expected {
+			t.Errorf("warning differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+		}
+	}
+}
+
+func TestStructNamespace(t *testing.T) {
+	server := struct {
+		Addr string `flag:"addr" env:"ADDR" usage:"listen address"`
+	}{Addr: ":80"}
+	db := struct {
+		Host string `flag:"host" env:"HOST" usage:"database host"`
+		Pool struct {
+			Size int `flag:"size" env:"SIZE"`
+		} `flag:"pool" env:"POOL"`
+	}{Host: "localhost"}
+
+	os.Setenv("DB_POOL_SIZE", "4")
+	defer os.Unsetenv("DB_POOL_SIZE")
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	err := s.Struct(&server)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = s.StructNamespace("db", &db)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = s.ParseEnv()
+	if err == nil {
+		err = s.Parse([]string{"-addr=:8080", "-db.host=db", "-help"})
+	}
+	if err != flag.ErrHelp {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if server.Addr != ":8080" || db.Host != "db" || db.Pool.Size != 4 {
+		t.Errorf("unexpected configuration %+v, %+v", server, db)
+	}
+	if s.Origin("db.Pool.Size") != "$DB_POOL_SIZE" {
+		t.Errorf("unexpected origin %q", s.Origin("db.Pool.Size"))
+	}
+
+	expected := "Usage of program:\n" +
+		"  -addr string\n    \tlisten address (default \":80\")\n" +
+		"\n" +
+		"db:\n" +
+		"  -db.host string\n    \tdatabase host (default \"localhost\")\n" +
+		"  -db.pool.size int\n    \t\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expected, buf.String())
+	}
+
+	conflicts := []struct {
+		namespace string
+		conf      interface{}
+		err       string
+	}{
+		{"", &struct {
+			Addr string `flag:"addr"`
+		}{}, "flag -addr of Addr is already defined"},
+		{"", &struct {
+			Host string `env:"DB_HOST"`
+		}{}, "environment key DB_HOST of Host is already used by db.Host"},
+		{"cache", &struct {
+			A string `flag:"a"`
+			B string `flag:"a"`
+		}{}, "flag -cache.a of cache.B is already defined by cache.A"},
+	}
+	for _, c := range conflicts {
+		err = s.StructNamespace(c.namespace, c.conf)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("expected error %q, got %v", c.err, err)
+		}
+	}
+}
+
+func TestPrintDefaultsFunc(t *testing.T) {
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	s.Func("tag", "add a ~tag~", func(string) error { return nil })
+	s.Int("n", 1, "count")
+
+	s.PrintDefaults()
+	expected := "  -n int\n    \tcount (default 1)\n" +
+		"  -tag tag\n    \tadd a tag\n"
+	if buf.String() != expected {
+		t.Errorf("output differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/Benzinga/flagstruct
+
+go 1.19
@@ -0,0 +1,95 @@
+package flagstruct
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// keyDirData is the symbolic link through which Kubernetes atomically swaps the
+// contents of mounted ConfigMap and Secret volumes.
+const keyDirData = "..data"
+
+// SetKeyDirs sets directories holding one file per key to be loaded by
+// ParseConfig, such as mounted Kubernetes ConfigMaps. See LoadKeyDir.
+func (s *FlagSet) SetKeyDirs(dirs ...string) {
+	s.keyDirs = dirs
+}
+
+// SetKeyDirSeparator sets the separator used in place of "." between the
+// names of nested structs and their members in file names of key directories.
+// For example, with the separator "__", the file "db__host" sets the flag
+// "db.host".
+func (s *FlagSet) SetKeyDirSeparator(sep string) {
+	s.keyDirSep = sep
+}
+
+// LoadKeyDir loads a directory holding one file per key. Each file is named
+// after a flag or an environment key, and holds its value without a trailing
+// newline. Hidden files are ignored. If the directory contains a "..data"
+// link, as Kubernetes volumes do, the files are read through it, so the values
+// come from a single version of the volume.
+func (s *FlagSet) LoadKeyDir(dir string) error {
+	err := s.loadKeyDir(dir)
+	if err != nil {
+		return s.failf("%v", err)
+	}
+	return nil
+}
+
+func (s *FlagSet) loadKeyDir(dir string) error {
+	fsys, dir, root, err := s.resolve(dir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.Stat(fsys, path.Join(root, keyDirData)); err == nil {
+		root = path.Join(root, keyDirData)
+	}
+
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || e.IsDir() {
+			continue
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(root, name))
+		if err != nil {
+			return err
+		}
+		value := strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r")
+
+		p := filepath.Join(dir, name)
+		f := s.lookup(s.keyDirName(name))
+		if f == nil {
+			f = s.env[name]
+		}
+		if f == nil {
+			return fmt.Errorf("%s: unknown flag or environment key %q", p, name)
+		}
+
+		err = f.set(value)
+		if err != nil {
+			return f.invalidValue(p, true, err)
+		}
+		s.setOrigin(f, p, "key file "+p)
+	}
+
+	s.configFiles = append(s.configFiles, dir)
+	return nil
+}
+
+// keyDirName returns the flag name for a file name in a key directory.
+func (s *FlagSet) keyDirName(name string) string {
+	if s.keyDirSep == "" {
+		return name
+	}
+	return strings.ReplaceAll(name, s.keyDirSep, ".")
+}
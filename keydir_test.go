@@ -0,0 +1,88 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadKeyDir(t *testing.T) {
+	type dbConfig struct {
+		Host string `flag:"host"`
+		Port int    `flag:"port"`
+	}
+	conf := struct {
+		Name  string   `flag:"name"`
+		Level int      `env:"KEYDIR_LEVEL"`
+		DB    dbConfig `flag:"db"`
+	}{}
+
+	// Lay out the directory as Kubernetes does.
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2024_01_01_00_00_00.000000000")
+	os.Mkdir(data, 0755)
+	files := map[string]string{
+		"name":         "configmap\n",
+		"KEYDIR_LEVEL": "3",
+		"db__host":     "db.local",
+	}
+	for name, contents := range files {
+		os.WriteFile(filepath.Join(data, name), []byte(contents), 0644)
+		os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name))
+	}
+	os.Symlink(filepath.Base(data), filepath.Join(dir, "..data"))
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("x"), 0644)
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetKeyDirs(dir)
+	s.SetKeyDirSeparator("__")
+	err := s.Configure(&conf, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Name != "configmap" || conf.Level != 3 || conf.DB.Host != "db.local" {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	if origin := s.Origin("DB.Host"); origin != filepath.Join(dir, "db__host") {
+		t.Errorf("unexpected origin %q", origin)
+	}
+
+	if files := s.ConfigFiles(); len(files) != 1 || files[0] != dir {
+		t.Errorf("unexpected configuration files %q", files)
+	}
+}
+
+func TestLoadKeyDirErrors(t *testing.T) {
+	conf := struct {
+		Port int `flag:"db.port"`
+	}{}
+
+	tests := []struct {
+		fsys fstest.MapFS
+		err  string
+	}{
+		{fstest.MapFS{"conf/other": {Data: []byte("1")}}, `/conf/other: unknown flag or environment key "other"`},
+		{fstest.MapFS{"conf/db.port": {Data: []byte("hunter2")}}, `/conf/db.port: invalid value, expected int`},
+		{fstest.MapFS{}, `open conf: file does not exist`},
+	}
+
+	for _, test := range tests {
+		s := NewFlagSet("program", flag.ContinueOnError)
+		s.SetOutput(&bytes.Buffer{})
+		s.SetConfigFS(test.fsys)
+		s.Struct(&conf)
+		err := s.LoadKeyDir("/conf")
+		if err == nil || err.Error() != test.err {
+			t.Errorf("unexpected error %v, expected %v", err, test.err)
+		}
+		if err != nil && strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("error %v quotes the contents of the file", err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package flagstruct
+
+import "reflect"
+
+// A Defaulter returns the default values of a configuration. Load uses the
+// Defaults method of the configuration type, if any, to initialize it.
+type Defaulter[T any] interface {
+	Defaults() T
+}
+
+// Load allocates a T, initializes it with its defaults and configures it from
+// the sources of CommandLine, as Configure does. See LoadSet.
+func Load[T any](opts ...Option) (*T, error) {
+	return LoadSet[T](CommandLine, opts...)
+}
+
+// LoadSet allocates a T, initializes it with its defaults and configures it
+// from the sources of s and the command-line arguments, as Configure does.
+// The defaults are those set with WithDefaults, or returned by the Defaults
+// method of T or *T, if any, or else the zero value. T must be a struct type;
+// other types are reported as errors. Options customizing the flag set are
+// applied to s.
+func LoadSet[T any](s *FlagSet, opts ...Option) (*T, error) {
+	o := newOptions(opts)
+	o.apply(s)
+
+	conf := new(T)
+	if reflect.TypeOf(conf).Elem().Kind() != reflect.Struct {
+		return nil, s.failf("%v", notStructError{conf})
+	}
+
+	switch d := o.defaults.(type) {
+	case nil:
+		if v, ok := interface{}(conf).(Defaulter[T]); ok {
+			*conf = v.Defaults()
+		}
+	case T:
+		*conf = d
+	default:
+		return nil, s.failf("defaults of type %T given for %T", d, conf)
+	}
+
+	err := s.Configure(conf, o.args)
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
@@ -0,0 +1,61 @@
+package flagstruct
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+type loadConfig struct {
+	Name string `flag:"name"`
+	Port int    `flag:"port"`
+}
+
+func (c loadConfig) Defaults() loadConfig {
+	return loadConfig{Name: "api", Port: 80}
+}
+
+func TestLoadSet(t *testing.T) {
+	s := NewFlagSet("program", flag.ContinueOnError)
+	conf, err := LoadSet[loadConfig](s, WithArgs([]string{"-port=8080"}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if *conf != (loadConfig{Name: "api", Port: 8080}) {
+		t.Errorf("unexpected configuration %+v", *conf)
+	}
+	if s.Lookup("port").DefValue != "80" {
+		t.Errorf("unexpected default %q", s.Lookup("port").DefValue)
+	}
+
+	s = NewFlagSet("program", flag.ContinueOnError)
+	conf, err = LoadSet[loadConfig](s, WithArgs(nil), WithDefaults(loadConfig{Name: "web"}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if *conf != (loadConfig{Name: "web"}) {
+		t.Errorf("unexpected configuration %+v", *conf)
+	}
+}
+
+func TestLoadSetErrors(t *testing.T) {
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(io.Discard)
+
+	_, err := LoadSet[int](s, WithArgs(nil))
+	if err == nil || err.Error() != "expected a pointer to a struct, got *int" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = LoadSet[loadConfig](s, WithArgs(nil), WithDefaults("api"))
+	if err == nil || err.Error() != "defaults of type string given for *flagstruct.loadConfig" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	for _, conf := range []interface{}{nil, loadConfig{}, new(int), (*loadConfig)(nil)} {
+		err = NewFlagSet("program", flag.ContinueOnError).Struct(conf)
+		if err == nil {
+			t.Errorf("expected error for %#v", conf)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package flagstruct
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A Naming derives the flag name and environment key of a struct member that
+// has no flag or env tag from its field name. Empty names leave the member
+// without flag or environment key, as does a "-" tag.
+type Naming func(field string) (name, key string)
+
+// KebabCase is a Naming deriving the flag name "max-conns" and the
+// environment key "MAX_CONNS" from the field MaxConns. Initialisms are kept
+// together, so HTTPPort is named "http-port".
+func KebabCase(field string) (name, key string) {
+	var b strings.Builder
+	runes := []rune(field)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && next {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	name = b.String()
+	return name, envKey(name)
+}
+
+// SetNaming sets the naming of the struct members that have no flag or env
+// tag. By default, they are not loaded. It applies to the structs loaded
+// afterwards.
+func (s *FlagSet) SetNaming(naming Naming) {
+	s.naming = naming
+}
+
+// SetEnvPrefix sets a prefix joined with "_" to the environment keys of the
+// structs loaded afterwards, such as "MYAPP" for MYAPP_PORT.
+func (s *FlagSet) SetEnvPrefix(prefix string) {
+	s.envPrefix = prefix
+}
+
+// SetFlagPrefix sets a prefix joined with "." to the flag names of the
+// structs loaded afterwards, such as "app" for -app.port. Namespaces follow
+// it.
+func (s *FlagSet) SetFlagPrefix(prefix string) {
+	s.flagPrefix = prefix
+}
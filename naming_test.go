@@ -0,0 +1,60 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+)
+
+func TestKebabCase(t *testing.T) {
+	tests := []struct {
+		field, name, key string
+	}{
+		{"Port", "port", "PORT"},
+		{"MaxConns", "max-conns", "MAX_CONNS"},
+		{"HTTPPort", "http-port", "HTTP_PORT"},
+		{"DBHost", "db-host", "DB_HOST"},
+		{"ID", "id", "ID"},
+		{"Retry2Delay", "retry2-delay", "RETRY2_DELAY"},
+	}
+	for _, test := range tests {
+		name, key := KebabCase(test.field)
+		if name != test.name || key != test.key {
+			t.Errorf("KebabCase(%q) = %q, %q, expected %q, %q", test.field, name, key, test.name, test.key)
+		}
+	}
+}
+
+func TestNaming(t *testing.T) {
+	conf := struct {
+		MaxConns int
+		Host     string `flag:"addr"`
+		Internal string `flag:"-" env:"-"`
+		DB       struct {
+			Port int
+		}
+	}{}
+
+	env := map[string]string{"APP_MAX_CONNS": "10", "APP_HOST": "example.com", "APP_DB_PORT": "5432"}
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError,
+		WithOutput(&buf),
+		WithNaming(KebabCase),
+		WithEnvPrefix("APP"),
+		WithFlagPrefix("app"),
+		WithLookupEnv(func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}),
+	)
+	err := s.Configure(&conf, []string{"-app.db.port=5433"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if conf.MaxConns != 10 || conf.Host != "example.com" || conf.DB.Port != 5433 {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+	if s.Lookup("app.addr") == nil || s.Lookup("app.internal") != nil {
+		t.Error("unexpected flags")
+	}
+}
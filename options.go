@@ -0,0 +1,191 @@
+package flagstruct
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"text/template"
+)
+
+// An Option customizes a flag set made by NewFlagSet, or a call to Configure,
+// Load or LoadSet.
+type Option func(*options)
+
+// options holds the settings given by options.
+type options struct {
+	args      []string
+	defaults  interface{}
+	lookupEnv func(key string) (string, bool)
+	set       []func(s *FlagSet)
+}
+
+// newOptions applies opts to the default settings, parsing os.Args[1:].
+func newOptions(opts []Option) *options {
+	o := &options{args: os.Args[1:]}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// apply applies the options customizing the flag set s, starting with the
+// lookup function of environment variables, which the others may use.
+func (o *options) apply(s *FlagSet) {
+	if o.lookupEnv != nil {
+		s.lookupEnvFunc = o.lookupEnv
+	}
+	for _, fn := range o.set {
+		fn(s)
+	}
+}
+
+// setOption returns an Option customizing the flag set with fn.
+func setOption(fn func(s *FlagSet)) Option {
+	return func(o *options) {
+		o.set = append(o.set, fn)
+	}
+}
+
+// WithArgs sets the command-line arguments parsed by Configure, Load or
+// LoadSet, instead of os.Args[1:].
+func WithArgs(arguments []string) Option {
+	return func(o *options) {
+		o.args = arguments
+	}
+}
+
+// WithDefaults sets the default values of the configuration allocated by Load
+// or LoadSet, instead of its Defaults method or its zero value. T must be the
+// type being loaded.
+func WithDefaults[T any](defaults T) Option {
+	return func(o *options) {
+		o.defaults = defaults
+	}
+}
+
+// WithOutput sets the destination for usage and error messages, see
+// SetOutput.
+func WithOutput(output io.Writer) Option {
+	return setOption(func(s *FlagSet) { s.SetOutput(output) })
+}
+
+// WithLookupEnv sets the function environment variables are looked up with,
+// instead of os.LookupEnv. It is also used for the variables locating default
+// configuration and secret directories, such as $XDG_CONFIG_HOME.
+func WithLookupEnv(lookup func(key string) (string, bool)) Option {
+	return func(o *options) {
+		o.lookupEnv = lookup
+	}
+}
+
+// WithEnvPrefix sets the prefix of environment keys, see SetEnvPrefix.
+func WithEnvPrefix(prefix string) Option {
+	return setOption(func(s *FlagSet) { s.SetEnvPrefix(prefix) })
+}
+
+// WithFlagPrefix sets the prefix of flag names, see SetFlagPrefix.
+func WithFlagPrefix(prefix string) Option {
+	return setOption(func(s *FlagSet) { s.SetFlagPrefix(prefix) })
+}
+
+// WithNaming sets the naming of untagged struct members, see SetNaming.
+func WithNaming(naming Naming) Option {
+	return setOption(func(s *FlagSet) { s.SetNaming(naming) })
+}
+
+// WithArgsEnv sets the environment variable holding additional command-line
+// arguments, see SetArgsEnv.
+func WithArgsEnv(key string) Option {
+	return setOption(func(s *FlagSet) { s.SetArgsEnv(key) })
+}
+
+// WithConfigPaths enables loading configuration files from directories, see
+// SetConfigPaths.
+func WithConfigPaths(dirs ...string) Option {
+	return setOption(func(s *FlagSet) { s.SetConfigPaths(dirs...) })
+}
+
+// WithConfigFS sets the file system files are read from, see SetConfigFS.
+func WithConfigFS(fsys fs.FS) Option {
+	return setOption(func(s *FlagSet) { s.SetConfigFS(fsys) })
+}
+
+// WithDotenvOverride sets whether dotenv files take precedence over the
+// environment, see SetDotenvOverride.
+func WithDotenvOverride(override bool) Option {
+	return setOption(func(s *FlagSet) { s.SetDotenvOverride(override) })
+}
+
+// WithFileEnv sets whether environment keys may be read from files, see
+// SetFileEnv.
+func WithFileEnv(enabled bool) Option {
+	return setOption(func(s *FlagSet) { s.SetFileEnv(enabled) })
+}
+
+// WithSecretDirs enables reading environment keys from files in directories,
+// see SetSecretDirs.
+func WithSecretDirs(dirs ...string) Option {
+	return setOption(func(s *FlagSet) { s.SetSecretDirs(dirs...) })
+}
+
+// WithKeyDirs enables loading key directories, see SetKeyDirs.
+func WithKeyDirs(dirs ...string) Option {
+	return setOption(func(s *FlagSet) { s.SetKeyDirs(dirs...) })
+}
+
+// WithKeyDirSeparator sets how file names in key directories map to nested
+// flag names, see SetKeyDirSeparator.
+func WithKeyDirSeparator(sep string) Option {
+	return setOption(func(s *FlagSet) { s.SetKeyDirSeparator(sep) })
+}
+
+// WithDefaultPolicy sets how members tagged with default that already have a
+// value are handled, see SetDefaultPolicy.
+func WithDefaultPolicy(policy DefaultPolicy) Option {
+	return setOption(func(s *FlagSet) { s.SetDefaultPolicy(policy) })
+}
+
+// WithDocOtherFlags sets whether generated documentation lists the flags not
+// loaded from structs, see SetDocOtherFlags.
+func WithDocOtherFlags(document bool) Option {
+	return setOption(func(s *FlagSet) { s.SetDocOtherFlags(document) })
+}
+
+// WithHelpWidth sets the width of usage output, see SetHelpWidth.
+func WithHelpWidth(width int) Option {
+	return setOption(func(s *FlagSet) { s.SetHelpWidth(width) })
+}
+
+// WithHelpEnv sets whether usage output shows environment variables, see
+// SetHelpEnv.
+func WithHelpEnv(show bool) Option {
+	return setOption(func(s *FlagSet) { s.SetHelpEnv(show) })
+}
+
+// WithHelpSort sets whether usage output sorts flags, see SetHelpSort.
+func WithHelpSort(sort bool) Option {
+	return setOption(func(s *FlagSet) { s.SetHelpSort(sort) })
+}
+
+// WithHelpLive sets whether usage output shows current values, see
+// SetHelpLive.
+func WithHelpLive(live bool) Option {
+	return setOption(func(s *FlagSet) { s.SetHelpLive(live) })
+}
+
+// WithHelpVerbose sets whether usage output lists deprecated flags, see
+// SetHelpVerbose.
+func WithHelpVerbose(verbose bool) Option {
+	return setOption(func(s *FlagSet) { s.SetHelpVerbose(verbose) })
+}
+
+// WithArgsUsage sets the usage of the positional arguments, see SetArgsUsage.
+func WithArgsUsage(usage string) Option {
+	return setOption(func(s *FlagSet) { s.SetArgsUsage(usage) })
+}
+
+// WithUsageTemplate sets the template rendering usage output, see
+// SetUsageTemplate.
+func WithUsageTemplate(t *template.Template) Option {
+	return setOption(func(s *FlagSet) { s.SetUsageTemplate(t) })
+}
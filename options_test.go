@@ -0,0 +1,92 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	conf := struct {
+		Name string `flag:"name" env:"NAME" usage:"service name"`
+		Port int    `flag:"port" env:"PORT"`
+	}{}
+
+	env := map[string]string{"NAME": "api", "FLAGS": "-port=8080"}
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError,
+		WithOutput(&buf),
+		WithLookupEnv(func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}),
+		WithArgsEnv("FLAGS"),
+		WithHelpEnv(true),
+	)
+
+	os.Setenv("NAME", "ignored")
+	defer os.Unsetenv("NAME")
+
+	err := s.Configure(&conf, []string{"-help"})
+	if err != flag.ErrHelp {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if conf.Name != "api" || conf.Port != 8080 {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	expected := "Usage of program:\n" +
+		"  -name string ($NAME)\n    \tservice name\n" +
+		"  -port int ($PORT)\n    \t\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected: %q\nactual:   %q", expected, buf.String())
+	}
+}
+
+func TestConfigureOptions(t *testing.T) {
+	conf := struct {
+		Name string `flag:"name" env:"NAME"`
+	}{}
+
+	defer func(s *FlagSet) { CommandLine = s }(CommandLine)
+	CommandLine = NewFlagSet("program", flag.ContinueOnError)
+
+	err := Configure(&conf, WithArgs([]string{"-name=web"}), WithLookupEnv(func(string) (string, bool) {
+		return "api", true
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if conf.Name != "web" || CommandLine.Origin("Name") != originFlag {
+		t.Errorf("unexpected configuration %+v from %s", conf, CommandLine.Origin("Name"))
+	}
+}
+
+func TestLookupEnvOptions(t *testing.T) {
+	env := map[string]string{
+		"XDG_CONFIG_HOME":       "/xdg",
+		"PROGRAM_CONFIG_PATH":   "/opt/program",
+		"CREDENTIALS_DIRECTORY": "/creds",
+	}
+
+	// The lookup function applies to the options given before it.
+	s := NewFlagSet("program", flag.ContinueOnError,
+		WithConfigPaths(),
+		WithSecretDirs(),
+		WithLookupEnv(func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}),
+	)
+
+	expected := []string{"/etc/program", "/xdg/program", ".", "/opt/program"}
+	if !reflect.DeepEqual(s.configPaths, expected) {
+		t.Errorf("unexpected configuration paths %q", s.configPaths)
+	}
+	expected = []string{"/run/secrets", "/creds"}
+	if !reflect.DeepEqual(s.secretDirs, expected) {
+		t.Errorf("unexpected secret directories %q", s.secretDirs)
+	}
+}
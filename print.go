@@ -3,8 +3,13 @@ package flagstruct
 import (
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // This is a copy of flag.UnquoteUsage that doesn't rely on flag.Flag.
@@ -60,12 +65,18 @@ func isZeroValue(value string) bool {
 }
 
 // PrintDefaults prints to standard error the default values of all
-// defined command-line flags in the set. This is copied from flag, adjusted
-// to allow ~ quotes in default values.
+// defined command-line flags in the set, except hidden and deprecated ones.
+// This is copied from flag, adjusted to allow ~ quotes in default values.
 func (s *FlagSet) PrintDefaults() {
 	s.VisitAll(func(f *flag.Flag) {
+		if !s.listed(f.Name) {
+			return
+		}
 		buf := fmt.Sprintf("  -%s", f.Name)
-		val := f.Value.(flag.Getter).Get()
+		var val interface{}
+		if g, ok := f.Value.(flag.Getter); ok {
+			val = g.Get()
+		}
 		name, usage := unquoteUsage(f.Usage, val)
 		if len(name) > 0 {
 			buf += " " + name
@@ -76,66 +87,415 @@ func (s *FlagSet) PrintDefaults() {
 			buf += "\n    \t"
 		}
 		buf += usage
+		fv := s.lookup(f.Name)
 		if !isZeroValue(f.DefValue) {
-			if _, ok := val.(string); ok {
+			if fv != nil && fv.secret {
+				buf += fmt.Sprintf(" (default %s)", redacted)
+			} else if _, ok := val.(string); ok {
 				buf += fmt.Sprintf(" (default %q)", f.DefValue)
 			} else {
 				buf += fmt.Sprintf(" (default %v)", f.DefValue)
 			}
 		}
+		if fv != nil && fv.deprecated != "" {
+			buf += fmt.Sprintf(" (deprecated: %s)", fv.deprecated)
+		}
 		fmt.Fprint(s.out(), buf, "\n")
 	})
 }
 
-// PrintStruct prints flags based on the struct passed to `conf`.
-func (s *FlagSet) PrintStruct(conf interface{}) {
-	v := reflect.ValueOf(conf).Elem()
-	t := reflect.TypeOf(conf).Elem()
+// helpGroup is a section of the output of PrintStruct.
+type helpGroup struct {
+	title       string
+	description string
+	entries     []helpEntry
+}
+
+// helpEntry is a flag listed by PrintStruct.
+type helpEntry struct {
+	name       string
+	aliases    []string
+	typn       string
+	env        string
+	usage      string
+	def        string
+	reload     string
+	required   bool
+	secret     bool
+	current    string
+	origin     string
+	deprecated string
+}
 
-	for i, l := 0, t.NumField(); i < l; i++ {
-		ft, fv := t.Field(i), v.Field(i)
+// SetHelpWidth sets the width of the output of PrintStruct. When it is not
+// zero, usage text is aligned in a column after the flags and wrapped to the
+// width. A negative width uses the width of the terminal, read from $COLUMNS,
+// or 80 columns. By default, the layout of the flag package is used.
+func (s *FlagSet) SetHelpWidth(width int) {
+	s.helpWidth = width
+}
+
+// SetHelpEnv sets whether PrintStruct shows the environment variable of each
+// flag next to it.
+func (s *FlagSet) SetHelpEnv(show bool) {
+	s.helpEnv = show
+}
+
+// SetHelpSort sets whether PrintStruct sorts flags alphabetically within each
+// group, instead of listing them in the order of the struct.
+func (s *FlagSet) SetHelpSort(sort bool) {
+	s.helpSort = sort
+}
+
+// SetHelpLive sets whether PrintStruct and the usage functions made by
+// MakeStructUsage show the current value of each flag and where it was set
+// from, next to its default value.
+func (s *FlagSet) SetHelpLive(live bool) {
+	s.helpLive = live
+}
+
+// HelpLiveFlag defines a boolean flag with the given name and usage that
+// enables live help when set, as SetHelpLive does, so that running the
+// program with the flag before -help shows the values it will use. The flag
+// is listed in the first section of usage output.
+func (s *FlagSet) HelpLiveFlag(name, usage string) {
+	s.BoolVar(&s.helpLive, name, false, usage)
+	s.ownFlags = append(s.ownFlags, name)
+}
+
+// Alias defines a flag named alias setting the same value as the flag named
+// name, listed with it in usage output rather than on its own. As with
+// flag.Var, defining a flag twice panics, and so does aliasing an undefined
+// flag.
+func (s *FlagSet) Alias(alias, name string) {
+	f := s.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("flagstruct: alias %s of undefined flag %s", alias, name))
+	}
+	if n, ok := s.aliases[name]; ok {
+		name = n
+	}
+	s.Var(f.Value, alias, fmt.Sprintf("alias for -%s", name))
+	if s.aliases == nil {
+		s.aliases = map[string]string{}
+	}
+	s.aliases[alias] = name
+}
+
+// aliasesOf returns the aliases of the flag named name, sorted.
+func (s *FlagSet) aliasesOf(name string) []string {
+	var aliases []string
+	for alias, n := range s.aliases {
+		if n == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// SetArgsUsage sets the usage of the positional arguments, such as
+// "FILE...", shown after the flags in the synopsis of usage output.
+func (s *FlagSet) SetArgsUsage(usage string) {
+	s.argsUsage = usage
+}
 
+// Subcommand adds a subcommand to the list in usage output, documentation and
+// completion scripts. Flag sets don't run subcommands: programs take them from
+// Args after parsing, and parse their flags with flag sets of their own.
+func (s *FlagSet) Subcommand(name, usage string) {
+	s.subcommands = append(s.subcommands, UsageCommand{Name: name, Usage: usage})
+}
+
+// SetHelpVerbose sets whether PrintStruct, PrintDefaults and the usage
+// functions list deprecated flags, with a note. Hidden flags are never listed.
+func (s *FlagSet) SetHelpVerbose(verbose bool) {
+	s.helpVerbose = verbose
+}
+
+// listed reports whether the registered flag named name is listed in help
+// output.
+func (s *FlagSet) listed(name string) bool {
+	f := s.lookup(name)
+	return f == nil || !f.hidden && (f.deprecated == "" || s.helpVerbose)
+}
+
+// helpGroups collects the flags of the struct passed to conf, in sections.
+// The first section holds the flags before any separator or group.
+func (s *FlagSet) helpGroups(conf interface{}) []*helpGroup {
+	groups := []*helpGroup{{}}
+	var nested *structField
+
+	s.walk(conf, func(sf structField) error {
 		// _ can be used to separate sections.
-		if ft.Name == "_" {
-			fmt.Fprint(s.out(), "\n")
-			continue
+		if sf.Name == "_" {
+			title, description := groupTitle(&sf)
+			groups = append(groups, &helpGroup{title: title, description: description})
+			return nil
+		}
+
+		if sf.name == "" || sf.tag("hidden") == "true" {
+			return nil
+		}
+
+		// Deprecated flags are only listed in verbose help.
+		deprecated := sf.Tag.Get("deprecated")
+		if deprecated != "" && !s.helpVerbose {
+			return nil
+		}
+
+		// Nested structs with a group tag form sections.
+		if g := groupOf(&sf); g != nested {
+			nested = g
+			if g != nil {
+				title, description := groupTitle(g)
+				groups = append(groups, &helpGroup{title: title, description: description})
+			} else {
+				groups = append(groups, &helpGroup{})
+			}
 		}
 
-		// Skip unexported fields.
-		if ft.PkgPath != "" {
-			continue
+		e := helpEntry{
+			name:       sf.name,
+			aliases:    s.aliasesOf(sf.name),
+			env:        sf.env,
+			reload:     sf.tag("reload"),
+			required:   sf.Tag.Get("required") == "true",
+			secret:     sf.tag("secret") == "true",
+			deprecated: deprecated,
 		}
+		e.typn, e.usage, e.def = describe(sf)
+		if e.secret && e.def != "" {
+			e.def = redacted
+		}
+
+		// Live help shows the current value, and the default recorded
+		// by Struct.
+		if f := s.lookup(sf.name); s.helpLive && f != nil {
+			e.def, e.origin = f.def, f.origin
+			e.current = f.value.String()
+			if _, ok := f.value.Get().(string); ok {
+				e.current = fmt.Sprintf("%q", e.current)
+			}
+			if f.secret {
+				e.current = redacted
+				if e.def != "" {
+					e.def = redacted
+				}
+			}
+		}
+
+		g := groups[len(groups)-1]
+		g.entries = append(g.entries, e)
+		return nil
+	})
+
+	if s.helpSort {
+		for _, g := range groups {
+			sort.SliceStable(g.entries, func(i, j int) bool {
+				return g.entries[i].name < g.entries[j].name
+			})
+		}
+	}
 
-		name, usage := ft.Tag.Get("flag"), ft.Tag.Get("usage")
-		if name == "" {
-			continue
+	return groups
+}
+
+// PrintStruct prints flags based on the struct passed to `conf`. The flags
+// are grouped in sections, separated by "_" members; a separator tagged
+// group:"Title", or usage:"Title", titles its section, and its description
+// tag describes it. Nested structs tagged with group form a section of their
+// own.
+func (s *FlagSet) PrintStruct(conf interface{}) {
+	s.printGroups(s.helpGroups(conf))
+}
+
+// structGroups collects the flags of the structs loaded into the flag set, in
+// sections. The first section of a struct loaded in a namespace is titled by
+// it, unless it has a title.
+func (s *FlagSet) structGroups() []*helpGroup {
+	var groups []*helpGroup
+	for _, l := range s.structs {
+		g := s.helpGroups(l.conf)
+		if l.namespace != "" && g[0].title == "" {
+			g[0].title = l.namespace
 		}
+		groups = append(groups, g...)
+	}
+	return groups
+}
 
-		typn, usage := unquoteUsage(usage, fv.Interface())
-		val := fv.Interface()
+// printGroups prints sections of flags, as described by PrintStruct, with the
+// "groups" block of DefaultUsageTemplate.
+func (s *FlagSet) printGroups(groups []*helpGroup) {
+	err := defaultUsageTemplate.ExecuteTemplate(s.out(), "groups", s.usageData(groups).Groups)
+	if err != nil {
+		fmt.Fprintln(s.out(), err)
+	}
+}
 
-		buf := fmt.Sprintf("  -%s", name)
-		if len(typn) > 0 {
-			buf += " " + typn
+// helpLayout returns the column usage text is aligned at and the width it is
+// wrapped to, or zero for the layout of the flag package.
+func (s *FlagSet) helpLayout(groups []*helpGroup) (col, width int) {
+	// Align usage text after the longest flag, up to a limit.
+	for _, g := range groups {
+		for _, e := range g.entries {
+			if n := len(s.flagText(e)); n > col {
+				col = n
+			}
 		}
+	}
+	if col > 30 {
+		col = 30
+	}
+	col += 4
 
+	width = s.helpWidth
+	if width < 0 {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+		if width <= 0 {
+			width = 80
+		}
+	}
+	return col, width
+}
+
+// flagText returns the flag of e as printed by PrintStruct.
+func (s *FlagSet) flagText(e helpEntry) string {
+	buf := fmt.Sprintf("-%s", e.name)
+	for _, alias := range e.aliases {
+		buf += ", -" + alias
+	}
+	if len(e.typn) > 0 {
+		buf += " " + e.typn
+	}
+	if s.helpEnv && e.env != "" {
+		buf += fmt.Sprintf(" ($%s)", e.env)
+	}
+	return buf
+}
+
+// usageText returns the usage of e as printed by PrintStruct.
+func usageText(e helpEntry) string {
+	usage := e.usage
+
+	// Add default value if non-zero
+	if e.def != "" {
+		usage += fmt.Sprintf(" (default %s)", e.def)
+	}
+
+	// Add reload policy if set
+	if e.reload != "" {
+		usage += fmt.Sprintf(" (reload: %s)", e.reload)
+	}
+
+	// Add deprecation note for verbose help
+	if e.deprecated != "" {
+		usage += fmt.Sprintf(" (deprecated: %s)", e.deprecated)
+	}
+
+	// Add current value for live help
+	if e.origin != "" {
+		usage += fmt.Sprintf(" (value %s from %s)", e.current, e.origin)
+	}
+	return usage
+}
+
+// entryLine returns e as printed by PrintStruct, without its indentation:
+// with its usage aligned at column col and wrapped to width, or in the layout
+// of the flag package if width is zero.
+func (s *FlagSet) entryLine(e helpEntry, col, width int) string {
+	buf := "  " + s.flagText(e)
+	if width == 0 {
 		// Usage on same-line for short flags
 		if len(buf) <= 4 {
 			buf += "\t"
 		} else {
 			buf += "\n    \t"
 		}
+		return buf[2:] + usageText(e)
+	}
 
-		buf += usage
+	indent := strings.Repeat(" ", col)
+	lines := wrapText(usageText(e), width-col)
+	if len(buf)+2 > col {
+		buf += "\n" + indent
+	} else {
+		buf += indent[len(buf):]
+	}
+	buf += strings.Join(lines, "\n"+indent)
+	return strings.TrimRight(buf[2:], " ")
+}
 
-		// Add default value if non-zero
-		if val != reflect.Zero(fv.Type()).Interface() {
-			if _, ok := val.(string); ok {
-				buf += fmt.Sprintf(" (default %q)", val)
-			} else {
-				buf += fmt.Sprintf(" (default %v)", val)
+// wrapText splits text into lines of at most width characters, breaking at
+// spaces, or only at newlines if width is zero. Words longer than width are
+// not broken, and width is at least 20.
+func wrapText(text string, width int) []string {
+	if width == 0 {
+		return strings.Split(text, "\n")
+	}
+	if width < 20 {
+		width = 20
+	}
+
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width:
+				lines = append(lines, line)
+				line = word
+			default:
+				line += " " + word
 			}
 		}
-		fmt.Fprint(s.out(), buf, "\n")
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// groupTitle returns the title and description of the section started by a
+// "_" separator or formed by a nested struct: its group tag, or the usage tag
+// of a separator, and its description tag.
+func groupTitle(sf *structField) (title, description string) {
+	title = sf.Tag.Get("group")
+	if title == "" && sf.Name == "_" {
+		title = sf.Tag.Get("usage")
+	}
+	return title, sf.Tag.Get("description")
+}
+
+// groupOf returns the nearest nested struct containing a member that has a
+// group tag, if any.
+func groupOf(sf *structField) *structField {
+	for p := sf.parent; p != nil; p = p.parent {
+		if _, ok := p.Tag.Lookup("group"); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// describe returns the value name, usage and default value of a struct member,
+// as printed by PrintStruct. The default is empty if the member is zero, and
+// taken from its default tag if it is not set yet.
+func describe(sf structField) (typn, usage, def string) {
+	v := withDefault(sf)
+	typn, usage = unquoteUsage(sf.Tag.Get("usage"), v.Interface())
+	return typn, usage, defaultText(v)
+}
+
+// defaultText returns a default value as printed by PrintStruct, rendered by
+// its Value as flags take it, or an empty string if it is zero.
+func defaultText(v reflect.Value) string {
+	if v.IsZero() {
+		return ""
+	}
+	if _, ok := v.Interface().(string); ok {
+		return fmt.Sprintf("%q", v.Interface())
 	}
+	return valueString(v)
 }
@@ -0,0 +1,85 @@
+package flagstruct
+
+import (
+	"fmt"
+	"sort"
+)
+
+// override is a default value set with SetDefault.
+type override struct {
+	name  string
+	value string
+}
+
+// Register registers a struct to be loaded by Configure in a namespace, see
+// StructNamespace. Packages may register their configuration in init
+// functions, as flags of the flag package are defined. Registered structs are
+// listed in usage output after the struct passed to Configure, ordered by
+// namespace. Register panics if the namespace is already registered.
+func (s *FlagSet) Register(namespace string, conf interface{}) {
+	for _, r := range s.registered {
+		if r.namespace == namespace {
+			panic(fmt.Sprintf("flagstruct: namespace %q registered twice", namespace))
+		}
+	}
+	s.registered = append(s.registered, loadedStruct{namespace: namespace, conf: conf})
+}
+
+// SetDefault sets the default value of the member with the flag name, path or
+// environment key name, such as "db.timeout", "db.Timeout" or "DB_TIMEOUT",
+// overriding its value when Configure loads it, before sources are parsed. It
+// lets the main package change the defaults of structs registered by other
+// packages.
+func (s *FlagSet) SetDefault(name, value string) {
+	s.overrides = append(s.overrides, override{name, value})
+}
+
+// loadRegistered loads the registered structs, ordered by namespace, and
+// applies the defaults set with SetDefault.
+func (s *FlagSet) loadRegistered() error {
+	registered := s.registered
+	s.registered = nil
+	sort.SliceStable(registered, func(i, j int) bool {
+		return registered[i].namespace < registered[j].namespace
+	})
+
+	for _, r := range registered {
+		err := s.StructNamespace(r.namespace, r.conf)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, o := range s.overrides {
+		f := s.member(o.name)
+		if f == nil {
+			return s.failf("cannot set default of undefined member %s", o.name)
+		}
+		err := f.set(o.value)
+		if err != nil {
+			return s.failf("invalid default %q for %s: %v", o.value, o.name, err)
+		}
+		f.initial, f.def = copyValue(f.member), defaultText(f.member)
+		if fl := s.Lookup(f.name); f.name != "" && fl != nil {
+			fl.DefValue = f.value.String()
+		}
+	}
+	s.applied = append(s.applied, s.overrides...)
+	s.overrides = nil
+
+	return nil
+}
+
+// member returns the field with the flag name, path or environment key name,
+// in that order, if any.
+func (s *FlagSet) member(name string) *field {
+	if f := s.lookup(name); f != nil {
+		return f
+	}
+	for _, f := range s.fields {
+		if f.path == name {
+			return f
+		}
+	}
+	return s.env[name]
+}
@@ -0,0 +1,76 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	http := struct {
+		Addr string `flag:"addr" usage:"listen address"`
+	}{Addr: ":80"}
+	db := struct {
+		Timeout time.Duration `flag:"timeout" env:"TIMEOUT"`
+		Retries int           `env:"RETRIES"`
+	}{Timeout: time.Second}
+	conf := struct {
+		Debug bool `flag:"debug"`
+	}{}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	s.Register("http", &http)
+	s.Register("db", &db)
+	s.SetDefault("db.timeout", "5s")
+	s.SetDefault("http.Addr", ":81")
+	s.SetDefault("DB_RETRIES", "3")
+
+	err := s.Configure(&conf, []string{"-http.addr=:8080", "-help"})
+	if err != flag.ErrHelp {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if http.Addr != ":8080" || db.Timeout != 5*time.Second || db.Retries != 3 || s.Lookup("http.addr").DefValue != ":81" {
+		t.Errorf("unexpected configuration %+v, %+v", http, db)
+	}
+	if s.Origin("db.Timeout") != originDefault {
+		t.Errorf("unexpected origin %q", s.Origin("db.Timeout"))
+	}
+
+	expected := "Usage of program:\n" +
+		"  -debug\n    \t\n" +
+		"\n" +
+		"db:\n" +
+		"  -db.timeout duration\n    \t (default 5s)\n" +
+		"\n" +
+		"http:\n" +
+		"  -http.addr string\n    \tlisten address (default \":81\")\n"
+	if buf.String() != expected {
+		t.Errorf("usage output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expected, buf.String())
+	}
+
+	s.Reset()
+	if db.Timeout != 5*time.Second {
+		t.Errorf("unexpected default %v", db.Timeout)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&bytes.Buffer{}))
+	s.Register("db", &struct{}{})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for namespace registered twice")
+			}
+		}()
+		s.Register("db", &struct{}{})
+	}()
+
+	s.SetDefault("db.host", "localhost")
+	err := s.Configure(nil, nil)
+	if err == nil || err.Error() != "cannot set default of undefined member db.host" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
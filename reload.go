@@ -0,0 +1,372 @@
+package flagstruct
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// A Reloader holds a configuration that can be reloaded while the program is
+// running. Each reload builds a fresh copy of the configuration, starting from
+// the defaults, and runs the sources of the flag set again. The copy is only
+// published if it is valid, so readers never see a partial configuration.
+//
+// If *T implements a Validate() error method, it is called before each copy is
+// published.
+//
+// The other structs loaded into the flag set, such as those registered with
+// Register, are configured again in copies, so that their flags are still
+// accepted, but only the configuration of type T is published.
+//
+// Members tagged reload:"static" keep their value on reload: changes to them
+// are reported as requiring a restart, or rejected with SetRejectStatic.
+// Members are dynamic by default, or when tagged reload:"dynamic". Nested
+// structs pass their policy on to their members.
+type Reloader[T any] struct {
+	set          *FlagSet
+	conf         *T
+	defaults     T
+	args         []string
+	current      atomic.Pointer[T]
+	mu           sync.Mutex
+	callbacks    []func(old, new *T, report ChangeReport)
+	rejectStatic bool
+}
+
+// A ChangeReport describes the changes found by a reload.
+type ChangeReport struct {
+	// Changed lists the members whose new values were applied, as reported
+	// by Diff.
+	Changed Changes
+
+	// Restart lists the static members whose new values were not applied, as
+	// they require a restart.
+	Restart Changes
+}
+
+// String returns a summary of the report listing the paths of the changed
+// members, suitable for logging.
+func (r ChangeReport) String() string {
+	var parts []string
+	if len(r.Changed) > 0 {
+		parts = append(parts, "changed: "+strings.Join(r.Changed.Paths(), ", "))
+	}
+	if len(r.Restart) > 0 {
+		parts = append(parts, "requires restart: "+strings.Join(r.Restart.Paths(), ", "))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// NewReloader configures conf from the sources of s and arguments, as
+// Configure does, and returns a Reloader publishing it. The value of conf
+// before configuration serves as the defaults of later reloads, which do not
+// modify conf.
+func NewReloader[T any](s *FlagSet, conf *T, arguments []string) (*Reloader[T], error) {
+	r := &Reloader[T]{set: s, conf: conf, defaults: *conf, args: arguments}
+
+	err := s.Configure(conf, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validate(conf)
+	if err != nil {
+		return nil, s.failf("%v", err)
+	}
+
+	r.current.Store(conf)
+	return r, nil
+}
+
+// Get returns the current configuration, which must not be modified.
+func (r *Reloader[T]) Get() *T {
+	return r.current.Load()
+}
+
+// OnChange registers a function called after a reload finds changes, with the
+// old and new configuration and a report of the changes. If only static
+// members changed, nothing is published and new is the same as old.
+func (r *Reloader[T]) OnChange(fn func(old, new *T, report ChangeReport)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callbacks = append(r.callbacks, fn)
+}
+
+// SetRejectStatic sets whether reloads changing static members fail, instead
+// of applying the changes to dynamic members only.
+func (r *Reloader[T]) SetRejectStatic(reject bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejectStatic = reject
+}
+
+// Reload builds a fresh configuration and publishes it if it is valid and
+// differs from the current one. Errors do not affect the current
+// configuration. Callbacks registered with OnChange are called after the
+// reload completes, so they may use the Reloader.
+func (r *Reloader[T]) Reload() error {
+	r.mu.Lock()
+	notify, err := r.reload()
+	r.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+	return err
+}
+
+// reload builds and publishes a fresh configuration while r.mu is held. If it
+// changed, it returns a function calling the callbacks registered then.
+func (r *Reloader[T]) reload() (notify func(), err error) {
+	conf := new(T)
+	*conf = r.defaults
+
+	s, err := r.set.clone(r.conf)
+	if err == nil {
+		err = s.Configure(conf, r.args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reload: %v", err)
+	}
+
+	old := r.current.Load()
+	report, err := s.changes(old, conf)
+	if err != nil {
+		return nil, fmt.Errorf("reload: %v", err)
+	}
+	if len(report.Restart) > 0 {
+		if r.rejectStatic {
+			return nil, fmt.Errorf("reload: restart required to change %s", strings.Join(report.Restart.Paths(), ", "))
+		}
+
+		// Keep the current values of static members.
+		vo, vc := reflect.ValueOf(old).Elem(), reflect.ValueOf(conf).Elem()
+		for _, ch := range report.Restart {
+			fieldByPath(vc, ch.Path).Set(fieldByPath(vo, ch.Path))
+		}
+	}
+
+	err = validate(conf)
+	if err != nil {
+		return nil, fmt.Errorf("reload: %v", err)
+	}
+
+	if len(report.Changed) == 0 && len(report.Restart) == 0 {
+		return nil, nil
+	}
+
+	if len(report.Changed) > 0 {
+		r.current.Store(conf)
+	} else {
+		conf = old
+	}
+	callbacks := append([]func(old, new *T, report ChangeReport){}, r.callbacks...)
+	return func() {
+		for _, fn := range callbacks {
+			fn(old, conf, report)
+		}
+	}, nil
+}
+
+// Watch reloads the configuration when the process receives SIGHUP and, if
+// interval is positive, when polling every interval finds that configuration,
+// dotenv files or key directories have changed. It returns when ctx is done.
+// Reload errors are written to the output of the flag set.
+func (r *Reloader[T]) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	last := r.set.fingerprint()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick:
+			if r.set.fingerprint() == last {
+				continue
+			}
+		}
+
+		last = r.set.fingerprint()
+		if err := r.Reload(); err != nil {
+			fmt.Fprintln(r.set.out(), err)
+		}
+	}
+}
+
+// Reload policies, set with the reload tag.
+const (
+	reloadStatic  = "static"
+	reloadDynamic = "dynamic"
+)
+
+// validate calls the Validate method of conf, if any.
+func validate(conf interface{}) error {
+	if v, ok := conf.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// changes reports the flag and environment members that differ between the
+// structs pointed to by a and b, found by Diff with the naming of s, according
+// to their reload policy.
+func (s *FlagSet) changes(a, b interface{}) (ChangeReport, error) {
+	var report ChangeReport
+	err := diff(a, b, s.walk, func(sf structField, ch Change) {
+		if sf.tag("reload") == reloadStatic {
+			report.Restart = append(report.Restart, ch)
+		} else {
+			report.Changed = append(report.Changed, ch)
+		}
+	})
+	return report, err
+}
+
+// fieldByPath returns the member of the struct v named by path.
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		v = v.FieldByName(name)
+	}
+	return v
+}
+
+// clone returns a flag set with the same settings as s, which returns errors
+// instead of exiting and discards its output. The structs loaded into s,
+// except conf, are loaded again in copies of their values before loading, and
+// those registered in copies of their values, along with the defaults set
+// with SetDefault, for Configure to load conf. Flags not loaded from structs
+// are defined again with values discarding what they are set to, and aliases
+// set the flags of the clone. Dotenv files are read again.
+func (s *FlagSet) clone(conf interface{}) (*FlagSet, error) {
+	c := *s
+	c.FlagSet = flag.NewFlagSet(s.name, flag.ContinueOnError)
+	c.errorHandling = flag.ContinueOnError
+	c.SetOutput(io.Discard)
+	c.fields = nil
+	c.structs = nil
+	c.registered = nil
+	c.overrides = append(append([]override{}, s.applied...), s.overrides...)
+	c.applied = nil
+	c.env = map[string]*field{}
+	c.configFiles = nil
+	c.dotenv = nil
+	c.dotenvPaths = nil
+
+	for _, l := range s.structs {
+		if l.conf == conf {
+			continue
+		}
+		err := c.StructNamespace(l.namespace, newCopy(l.initial))
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.VisitAll(func(f *flag.Flag) {
+		if name, ok := s.aliases[f.Name]; ok {
+			c.Var(aliasValue{&c, name, isBoolFlag(f.Value)}, f.Name, f.Usage)
+		} else if s.lookup(f.Name) == nil {
+			c.Var(discardValue{isBoolFlag(f.Value)}, f.Name, f.Usage)
+		}
+	})
+	for _, r := range s.registered {
+		if v := reflect.ValueOf(r.conf); v.Kind() == reflect.Ptr && !v.IsNil() {
+			r.conf = newCopy(v.Elem())
+		}
+		c.registered = append(c.registered, r)
+	}
+
+	err := c.LoadDotenv(s.dotenvPaths...)
+	return &c, err
+}
+
+// discardValue is a flag value discarding what it is set to, standing for the
+// flags not loaded from structs in clones of flag sets.
+type discardValue struct {
+	boolFlag bool
+}
+
+func (v discardValue) String() string   { return "" }
+func (v discardValue) Set(string) error { return nil }
+func (v discardValue) IsBoolFlag() bool { return v.boolFlag }
+
+// aliasValue sets the flag named name in a clone of a flag set, for an alias
+// defined with Alias.
+type aliasValue struct {
+	set      *FlagSet
+	name     string
+	boolFlag bool
+}
+
+func (v aliasValue) String() string     { return "" }
+func (v aliasValue) Set(s string) error { return v.set.Set(v.name, s) }
+func (v aliasValue) IsBoolFlag() bool   { return v.boolFlag }
+
+// isBoolFlag reports whether v is the value of a boolean flag.
+func isBoolFlag(v flag.Value) bool {
+	b, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// newCopy returns a pointer to a copy of v.
+func newCopy(v reflect.Value) interface{} {
+	p := reflect.New(v.Type())
+	p.Elem().Set(copyValue(v))
+	return p.Interface()
+}
+
+// fingerprint summarizes the state of the files the sources of the flag set
+// read, so changes can be detected by polling.
+func (s *FlagSet) fingerprint() string {
+	var paths []string
+	for _, dir := range s.configPaths {
+		for _, ext := range configExts {
+			paths = append(paths, filepath.Join(dir, s.configName()+ext))
+		}
+	}
+	paths = append(paths, s.dotenvPaths...)
+	for _, dir := range s.keyDirs {
+		paths = append(paths, filepath.Join(dir, keyDirData))
+		if fsys, _, name, err := s.resolve(dir); err == nil {
+			entries, _ := fs.ReadDir(fsys, name)
+			for _, e := range entries {
+				paths = append(paths, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+
+	var buf strings.Builder
+	for _, p := range paths {
+		fsys, _, name, err := s.resolve(p)
+		if err != nil {
+			continue
+		}
+		if fi, err := fs.Stat(fsys, name); err == nil {
+			fmt.Fprintf(&buf, "%s %d %d\n", name, fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,314 @@
+package flagstruct
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type reloadConfig struct {
+	Host  string `flag:"host"`
+	Level int    `flag:"level"`
+	DB    struct {
+		Pool int `flag:"pool"`
+	} `flag:"db"`
+	Local int
+}
+
+func (c *reloadConfig) Validate() error {
+	if c.Level < 0 {
+		return errors.New("level must not be negative")
+	}
+	return nil
+}
+
+func TestReloader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/program.json": {Data: []byte(`{"host": "a", "level": 1}`)},
+	}
+
+	conf := &reloadConfig{Local: 7}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetConfigPaths("/etc")
+	r, err := NewReloader(s, conf, []string{"-db.pool=4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Get() != conf || conf.Host != "a" || conf.DB.Pool != 4 {
+		t.Errorf("unexpected configuration %+v", r.Get())
+	}
+
+	var calls int
+	var old, new *reloadConfig
+	var report ChangeReport
+	r.OnChange(func(o, n *reloadConfig, cr ChangeReport) {
+		calls++
+		old, new, report = o, n, cr
+	})
+
+	// Unchanged configurations are not published.
+	err = r.Reload()
+	if err != nil || calls != 0 || r.Get() != conf {
+		t.Errorf("unexpected reload: %v, %d calls", err, calls)
+	}
+
+	fsys["etc/program.json"].Data = []byte(`{"host": "b", "level": 2, "db": {"pool": 8}}`)
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 || old != conf || new != r.Get() {
+		t.Errorf("unexpected callback: %d calls, old %p, new %p", calls, old, new)
+	}
+	expected := Changes{{Path: "Host", Old: "a", New: "b"}, {Path: "Level", Old: "1", New: "2"}}
+	if !reflect.DeepEqual(report.Changed, expected) || report.Restart != nil {
+		t.Errorf("unexpected changes %v", report)
+	}
+	if new.Host != "b" || new.DB.Pool != 4 || new.Local != 7 || conf.Host != "a" {
+		t.Errorf("unexpected configuration %+v", new)
+	}
+
+	// Invalid configurations are not published.
+	for _, data := range []string{`{"level": -1}`, `{"level": "x"}`} {
+		fsys["etc/program.json"].Data = []byte(data)
+		err = r.Reload()
+		if err == nil || calls != 1 || r.Get() != new {
+			t.Errorf("unexpected reload of %s: %v, %d calls", data, err, calls)
+		}
+	}
+
+	conf = &reloadConfig{Level: -1}
+	s = NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&bytes.Buffer{})
+	_, err = NewReloader(s, conf, nil)
+	if err == nil {
+		t.Error("expected validation error")
+	}
+}
+
+func TestReloaderCallbacks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/program.json": {Data: []byte(`{"host": "a"}`)},
+	}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetConfigPaths("/etc")
+	r, err := NewReloader(s, &reloadConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Callbacks may use the Reloader.
+	var calls int
+	r.OnChange(func(old, new *reloadConfig, report ChangeReport) {
+		calls++
+		r.SetRejectStatic(true)
+		r.OnChange(func(old, new *reloadConfig, report ChangeReport) {})
+		if calls == 1 {
+			fsys["etc/program.json"].Data = []byte(`{"host": "c"}`)
+			if err := r.Reload(); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	fsys["etc/program.json"].Data = []byte(`{"host": "b"}`)
+	err = r.Reload()
+	if err != nil || calls != 2 || r.Get().Host != "c" {
+		t.Errorf("unexpected reload: %v, %d calls, %+v", err, calls, r.Get())
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "program.json")
+	os.WriteFile(p, []byte(`{"level": 1}`), 0644)
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigPaths(dir)
+	r, err := NewReloader(s, &reloadConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan int, 1)
+	r.OnChange(func(old, new *reloadConfig, report ChangeReport) {
+		changes <- new.Level
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	// Give the watcher time to take its first fingerprint.
+	time.Sleep(50 * time.Millisecond)
+	os.WriteFile(p, []byte(`{"level": 22}`), 0644)
+
+	select {
+	case level := <-changes:
+		if level != 22 {
+			t.Errorf("unexpected level %d", level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("configuration was not reloaded")
+	}
+}
+
+func TestReloadPolicy(t *testing.T) {
+	type config struct {
+		Listen string `flag:"listen" reload:"static"`
+		Level  int    `flag:"level" reload:"dynamic"`
+		DB     struct {
+			Pool int `flag:"pool"`
+		} `flag:"db" reload:"static"`
+	}
+
+	fsys := fstest.MapFS{
+		"etc/program.json": {Data: []byte(`{"listen": ":80", "level": 1}`)},
+	}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetConfigPaths("/etc")
+	r, err := NewReloader(s, &config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []ChangeReport
+	r.OnChange(func(old, new *config, report ChangeReport) {
+		reports = append(reports, report)
+	})
+
+	fsys["etc/program.json"].Data = []byte(`{"listen": ":81", "level": 2, "db": {"pool": 3}}`)
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := r.Get()
+	if conf.Listen != ":80" || conf.Level != 2 || conf.DB.Pool != 0 {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+	if len(reports) != 1 || reports[0].String() != "changed: Level; requires restart: Listen, DB.Pool" {
+		t.Errorf("unexpected reports %v", reports)
+	}
+
+	// Only static changes left: nothing is published.
+	err = r.Reload()
+	if err != nil || r.Get() != conf || len(reports) != 2 || reports[1].String() != "requires restart: Listen, DB.Pool" {
+		t.Errorf("unexpected reload: %v, reports %v", err, reports)
+	}
+
+	r.SetRejectStatic(true)
+	err = r.Reload()
+	if err == nil || err.Error() != "reload: restart required to change Listen, DB.Pool" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	buf := bytes.Buffer{}
+	s.SetOutput(&buf)
+	s.PrintStruct(&config{})
+	expectedp := "" +
+		"  -listen string\n    \t (reload: static)\n" +
+		"  -level int\n    \t (reload: dynamic)\n" +
+		"  -db.pool int\n    \t (reload: static)\n"
+	if buf.String() != expectedp {
+		t.Errorf("print output differs from expected.\nexpected:\n%q\nactual:\n%q\n", expectedp, buf.String())
+	}
+
+	s = NewFlagSet("program", flag.ContinueOnError)
+	err = s.Struct(&struct {
+		Level int `flag:"level" reload:"sometimes"`
+	}{})
+	if err == nil || err.Error() != `invalid reload policy "sometimes" for Level` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestReloaderNamespaces(t *testing.T) {
+	type storeConfig struct {
+		Host string `flag:"host"`
+		Pool int    `flag:"pool"`
+	}
+	type cacheConfig struct {
+		Size int `flag:"size"`
+	}
+
+	fsys := fstest.MapFS{
+		"etc/program.json": {Data: []byte(`{"host": "a"}`)},
+	}
+
+	store, cache := &storeConfig{Pool: 2}, &cacheConfig{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetConfigPaths("/etc")
+	s.StructNamespace("store", store)
+	s.Register("cache", cache)
+	s.SetDefault("cache.size", "64")
+	r, err := NewReloader(s, &reloadConfig{}, []string{"-store.host=x", "-cache.size=128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["etc/program.json"].Data = []byte(`{"host": "b", "store": {"pool": 4}}`)
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Get().Host != "b" {
+		t.Errorf("unexpected configuration %+v", r.Get())
+	}
+
+	// Structs other than the configuration are not modified by reloads.
+	if store.Host != "x" || store.Pool != 2 || cache.Size != 128 {
+		t.Errorf("unexpected structs %+v, %+v", store, cache)
+	}
+}
+
+func TestReloaderOtherFlags(t *testing.T) {
+	type storeConfig struct {
+		Host string `flag:"host"`
+	}
+
+	fsys := fstest.MapFS{
+		"etc/program.json": {Data: []byte(`{"host": "a"}`)},
+	}
+
+	store := &storeConfig{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetConfigPaths("/etc")
+	v := s.Bool("v", false, "verbose output")
+	s.Alias("verbose", "v")
+	s.HelpLiveFlag("print-config", "show current values in help")
+	s.StructNamespace("store", store)
+	s.Alias("s", "store.host")
+	r, err := NewReloader(s, &reloadConfig{}, []string{"-verbose", "-s=x", "-print-config", "-level=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["etc/program.json"].Data = []byte(`{"host": "b"}`)
+	err = r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Get().Host != "b" || r.Get().Level != 2 {
+		t.Errorf("unexpected configuration %+v", r.Get())
+	}
+	if !*v || store.Host != "x" {
+		t.Errorf("unexpected flags %v, %+v", *v, store)
+	}
+}
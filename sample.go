@@ -0,0 +1,344 @@
+package flagstruct
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sampleNode is a table, a member or a section in the tree written by
+// WriteSample.
+type sampleNode struct {
+	key      string
+	sf       *structField
+	value    Value
+	children []*sampleNode
+}
+
+// section reports whether the node is a "_" separator.
+func (n *sampleNode) section() bool {
+	return n.sf != nil && n.sf.Name == "_"
+}
+
+// commented reports whether the node is written as a comment only.
+func (n *sampleNode) commented() bool {
+	return n.section() || n.sf != nil && n.sf.tag("secret") == "true"
+}
+
+// table returns the child table of n named key, adding it if needed.
+func (n *sampleNode) table(key string) *sampleNode {
+	for _, c := range n.children {
+		if c.key == key && c.sf == nil {
+			return c
+		}
+	}
+	c := &sampleNode{key: key}
+	n.children = append(n.children, c)
+	return c
+}
+
+// WriteSample writes a sample configuration file in the given format, listing
+// every member of the struct passed to conf with its current value as the
+// default, along with its usage, environment variable or flag, and whether it
+// is required. Sections are separated and titled as in PrintStruct.
+// Members tagged secret:"true" are written commented out, with their values
+// redacted, and hidden and deprecated members are left out.
+//
+// The supported formats are "jsonc" (JSON with comments), "yaml", "toml" and
+// "env" (a dotenv file). The configuration file formats list members with
+// flags, and the dotenv format members with environment variables.
+func (s *FlagSet) WriteSample(w io.Writer, conf interface{}, format string) error {
+	var entries []*sampleNode
+	names := map[string]bool{}
+
+	err := s.walk(conf, func(sf structField) error {
+		n := &sampleNode{sf: &sf}
+		if sf.Name != "_" {
+			if sf.name == "" && sf.env == "" {
+				return nil
+			}
+			if sf.tag("hidden") == "true" || sf.Tag.Get("deprecated") != "" {
+				return nil
+			}
+			v, err := valueFromPointer(withDefault(sf).Addr().Interface())
+			if err != nil {
+				return err
+			}
+			n.value = v
+			names[sf.name] = true
+		}
+		entries = append(entries, n)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sw := &sampleWriter{names: names}
+	switch format {
+	case "jsonc", "yaml":
+		root := &sampleNode{}
+		for _, n := range entries {
+			if n.section() {
+				prefix := ""
+				if n.sf.parent != nil {
+					prefix = n.sf.parent.name
+				}
+				tables, _ := splitName(join(prefix, "_", "."), names)
+				t := sw.table(root, tables)
+				t.children = append(t.children, n)
+			} else if n.sf.name != "" {
+				tables, key := splitName(n.sf.name, names)
+				n.key = key
+				t := sw.table(root, tables)
+				t.children = append(t.children, n)
+			}
+		}
+		if format == "yaml" {
+			sw.yaml(root, "")
+		} else {
+			sw.open("{\n")
+			sw.jsonc(root, "  ")
+			sw.buf.WriteString("}\n")
+		}
+	case "toml":
+		for _, n := range entries {
+			switch {
+			case n.section():
+				sw.title("#", "", n.sf)
+			case n.sf.name != "":
+				sw.toml(n)
+			}
+		}
+	case "env":
+		for _, n := range entries {
+			switch {
+			case n.section():
+				sw.title("#", "", n.sf)
+			case n.sf.env != "":
+				sw.env(n)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported sample format %q", format)
+	}
+
+	_, err = io.WriteString(w, sw.buf.String())
+	return err
+}
+
+// sampleWriter writes sample configuration files.
+type sampleWriter struct {
+	buf    strings.Builder
+	names  map[string]bool
+	opened bool
+}
+
+// splitName splits a flag name into the names of its tables and its key, at
+// dots, unless a prefix of the name is one of the flag names in names.
+func splitName(name string, names map[string]bool) (tables []string, key string) {
+	parts := strings.Split(name, ".")
+	i := 0
+	for ; i < len(parts)-1 && !names[strings.Join(parts[:i+1], ".")]; i++ {
+	}
+	return parts[:i], strings.Join(parts[i:], ".")
+}
+
+// table returns the nested table of root named by tables.
+func (sw *sampleWriter) table(root *sampleNode, tables []string) *sampleNode {
+	for _, t := range tables {
+		root = root.table(t)
+	}
+	return root
+}
+
+// separate starts a new entry, separated from the previous one by a blank
+// line unless it is the first of its table.
+func (sw *sampleWriter) separate() {
+	if sw.buf.Len() > 0 && !sw.opened {
+		sw.buf.WriteString("\n")
+	}
+	sw.opened = false
+}
+
+// open writes the line opening a table.
+func (sw *sampleWriter) open(line string) {
+	sw.buf.WriteString(line)
+	sw.opened = true
+}
+
+// comments writes the usage and annotations of a member as comments. other
+// names the way to set the member not used by the format.
+func (sw *sampleWriter) comments(n *sampleNode, comment, indent, other string) {
+	sw.separate()
+	_, usage := unquoteUsage(n.sf.Tag.Get("usage"), n.value.Get())
+	for _, line := range strings.Split(usage, "\n") {
+		if line != "" {
+			fmt.Fprintf(&sw.buf, "%s%s %s\n", indent, comment, line)
+		}
+	}
+	if n.sf.Tag.Get("required") == "true" {
+		fmt.Fprintf(&sw.buf, "%s%s Required.\n", indent, comment)
+	}
+	if other != "" {
+		fmt.Fprintf(&sw.buf, "%s%s %s\n", indent, comment, other)
+	}
+}
+
+func (sw *sampleWriter) yaml(n *sampleNode, indent string) {
+	for _, c := range n.children {
+		switch {
+		case c.sf == nil:
+			sw.separate()
+			sw.open(indent + yamlQuote(c.key) + ":\n")
+			sw.yaml(c, indent+"  ")
+		case c.section():
+			sw.title("#", indent, c.sf)
+		default:
+			sw.comments(c, "#", indent, envComment(c.sf))
+			if c.commented() {
+				fmt.Fprintf(&sw.buf, "%s# %s: %s\n", indent, yamlQuote(c.key), redacted)
+			} else {
+				fmt.Fprintf(&sw.buf, "%s%s: %s\n", indent, yamlQuote(c.key), yamlValue(c.value))
+			}
+		}
+	}
+}
+
+func (sw *sampleWriter) jsonc(n *sampleNode, indent string) {
+	// Commas follow every value but the last.
+	last := -1
+	for i, c := range n.children {
+		if !c.commented() {
+			last = i
+		}
+	}
+
+	for i, c := range n.children {
+		comma := ","
+		if i >= last {
+			comma = ""
+		}
+		key, _ := json.Marshal(c.key)
+
+		switch {
+		case c.sf == nil:
+			sw.separate()
+			sw.open(fmt.Sprintf("%s%s: {\n", indent, key))
+			sw.jsonc(c, indent+"  ")
+			fmt.Fprintf(&sw.buf, "%s}%s\n", indent, comma)
+		case c.section():
+			sw.title("//", indent, c.sf)
+		default:
+			sw.comments(c, "//", indent, envComment(c.sf))
+			if c.commented() {
+				fmt.Fprintf(&sw.buf, "%s// %s: %q\n", indent, key, redacted)
+			} else {
+				fmt.Fprintf(&sw.buf, "%s%s: %s%s\n", indent, key, jsonValue(c.value), comma)
+			}
+		}
+	}
+}
+
+func (sw *sampleWriter) toml(n *sampleNode) {
+	sw.comments(n, "#", "", envComment(n.sf))
+
+	tables, key := splitName(n.sf.name, sw.names)
+	parts := append(tables, key)
+	for i, part := range parts {
+		if part == "" || strings.IndexFunc(part, func(r rune) bool { return r > 0x7f || !isTOMLBare(byte(r)) }) >= 0 {
+			parts[i] = tomlQuote(part)
+		}
+	}
+
+	if n.commented() {
+		fmt.Fprintf(&sw.buf, "# %s = %s\n", strings.Join(parts, "."), tomlQuote(redacted))
+	} else {
+		fmt.Fprintf(&sw.buf, "%s = %s\n", strings.Join(parts, "."), tomlValue(n.value))
+	}
+}
+
+func (sw *sampleWriter) env(n *sampleNode) {
+	other := ""
+	if n.sf.name != "" {
+		other = "Flag: -" + n.sf.name
+	}
+	sw.comments(n, "#", "", other)
+
+	if n.commented() {
+		fmt.Fprintf(&sw.buf, "# %s=%s\n", n.sf.env, redacted)
+	} else {
+		fmt.Fprintf(&sw.buf, "%s=%s\n", n.sf.env, dotenvQuote(n.value.String()))
+	}
+}
+
+// title writes the title and description of a section, if its separator has
+// any.
+func (sw *sampleWriter) title(comment, indent string, sf *structField) {
+	title, description := groupTitle(sf)
+	if title == "" && description == "" {
+		return
+	}
+
+	sw.separate()
+	if title != "" {
+		fmt.Fprintf(&sw.buf, "%s%s %s\n", indent, comment, title)
+	}
+	for _, line := range wrapText(description, 0) {
+		if line != "" {
+			fmt.Fprintf(&sw.buf, "%s%s %s\n", indent, comment, line)
+		}
+	}
+}
+
+// envComment describes the environment variable of a member, if any.
+func envComment(sf *structField) string {
+	if sf.env == "" {
+		return ""
+	}
+	return "Environment variable: " + sf.env
+}
+
+// tomlValue returns the TOML representation of v.
+func tomlValue(v Value) string {
+	if sv, ok := v.(sliceValue); ok {
+		a := make([]string, sv.v.Len())
+		for i := range a {
+			e, _ := valueFromPointer(sv.v.Index(i).Addr().Interface())
+			a[i] = tomlValue(e)
+		}
+		return "[" + strings.Join(a, ", ") + "]"
+	}
+
+	switch v.Get().(type) {
+	case bool, int, int64, uint, uint64, float64:
+		return v.String()
+	}
+	return tomlQuote(v.String())
+}
+
+// tomlQuote returns s as a TOML basic string.
+func tomlQuote(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&buf, `\u%04x`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
@@ -0,0 +1,183 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWriteSample(t *testing.T) {
+	conf := struct {
+		Name     string        `flag:"name" env:"SAMPLE_NAME" usage:"~name~ of the service" required:"true"`
+		Verbose  bool          `flag:"v" usage:"verbose output"`
+		Token    string        `env:"SAMPLE_TOKEN" usage:"API token"`
+		_        struct{}      `group:"Database"`
+		Timeout  time.Duration `flag:"db.timeout" env:"SAMPLE_DB_TIMEOUT" usage:"query timeout"`
+		Tags     []string      `flag:"db.tags" usage:"connection tags"`
+		Password string        `flag:"db.password" env:"SAMPLE_DB_PASSWORD" secret:"true"`
+	}{
+		Name:     "api",
+		Token:    "t0k",
+		Timeout:  time.Second,
+		Tags:     []string{"a", "b c"},
+		Password: "hunter2",
+	}
+
+	tests := map[string]string{
+		"jsonc": `{
+  // name of the service
+  // Required.
+  // Environment variable: SAMPLE_NAME
+  "name": "api",
+
+  // verbose output
+  "v": false,
+
+  // Database
+
+  "db": {
+    // query timeout
+    // Environment variable: SAMPLE_DB_TIMEOUT
+    "timeout": "1s",
+
+    // connection tags
+    "tags": ["a", "b c"]
+
+    // Environment variable: SAMPLE_DB_PASSWORD
+    // "password": "<redacted>"
+  }
+}
+`,
+		"yaml": `# name of the service
+# Required.
+# Environment variable: SAMPLE_NAME
+name: api
+
+# verbose output
+v: false
+
+# Database
+
+db:
+  # query timeout
+  # Environment variable: SAMPLE_DB_TIMEOUT
+  timeout: 1s
+
+  # connection tags
+  tags: [a, b c]
+
+  # Environment variable: SAMPLE_DB_PASSWORD
+  # password: <redacted>
+`,
+		"toml": `# name of the service
+# Required.
+# Environment variable: SAMPLE_NAME
+name = "api"
+
+# verbose output
+v = false
+
+# Database
+
+# query timeout
+# Environment variable: SAMPLE_DB_TIMEOUT
+db.timeout = "1s"
+
+# connection tags
+db.tags = ["a", "b c"]
+
+# Environment variable: SAMPLE_DB_PASSWORD
+# db.password = "<redacted>"
+`,
+		"env": `# name of the service
+# Required.
+# Flag: -name
+SAMPLE_NAME=api
+
+# API token
+SAMPLE_TOKEN=t0k
+
+# Database
+
+# query timeout
+# Flag: -db.timeout
+SAMPLE_DB_TIMEOUT=1s
+
+# Flag: -db.password
+# SAMPLE_DB_PASSWORD=<redacted>
+`,
+	}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	for format, expected := range tests {
+		buf := bytes.Buffer{}
+		err := s.WriteSample(&buf, &conf, format)
+		if err != nil {
+			t.Errorf("WriteSample(%q) returned error %v", format, err)
+		}
+		if buf.String() != expected {
+			t.Errorf("WriteSample(%q) differs from expected.\nexpected:\n%s\nactual:\n%s", format, expected, buf.String())
+		}
+	}
+
+	err := s.WriteSample(&bytes.Buffer{}, &conf, "xml")
+	if err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestWriteSampleRoundTrip(t *testing.T) {
+	type config struct {
+		Name     string        `flag:"name" env:"SAMPLE_NAME" usage:"~name~ of the service" required:"true"`
+		Verbose  bool          `flag:"v" usage:"verbose output"`
+		Token    string        `env:"SAMPLE_TOKEN" usage:"API token"`
+		_        struct{}      `group:"Database"`
+		Timeout  time.Duration `flag:"db.timeout" env:"SAMPLE_DB_TIMEOUT" usage:"query timeout"`
+		Tags     []string      `flag:"db.tags" usage:"connection tags"`
+		Password string        `flag:"db.password" env:"SAMPLE_DB_PASSWORD" secret:"true"`
+	}
+	sample := config{
+		Name:     "api",
+		Token:    "t0k",
+		Timeout:  time.Second,
+		Tags:     []string{"a", "b c"},
+		Password: "hunter2",
+	}
+
+	for _, format := range []string{"jsonc", "yaml", "toml", "env"} {
+		buf := bytes.Buffer{}
+		NewFlagSet("program", flag.ContinueOnError).WriteSample(&buf, &sample, format)
+
+		conf := &config{}
+		s := NewFlagSet("program", flag.ContinueOnError)
+		s.SetConfigFS(fstest.MapFS{"sample": {Data: buf.Bytes()}})
+		s.Struct(conf)
+
+		expected := sample
+		expected.Password = ""
+
+		var err error
+		if format == "env" {
+			// The tags only have a flag.
+			expected.Tags = nil
+			err = s.LoadDotenv("/sample")
+			if err == nil {
+				err = s.ParseEnv()
+			}
+		} else {
+			// The token only has an environment variable.
+			expected.Token = ""
+			err = s.LoadConfigFormat("/sample", format)
+		}
+		if err != nil {
+			t.Errorf("loading %s sample returned error %v", format, err)
+		}
+
+		if !reflect.DeepEqual(*conf, expected) {
+			t.Errorf("%s round trip differs.\nexpected: %+v\nactual:   %+v", format, expected, conf)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package flagstruct
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"time"
+)
+
+// jsonSchemaDialect identifies the JSON Schema draft written by
+// WriteJSONSchema.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaObject is a JSON object that keeps the order of its members.
+type schemaObject []schemaMember
+
+type schemaMember struct {
+	key   string
+	value interface{}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o schemaObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// schemaTable is the schema of a table of a configuration file.
+type schemaTable struct {
+	properties schemaObject
+	required   []string
+}
+
+// table returns the child table of t named key, adding it if needed.
+func (t *schemaTable) table(key string) *schemaTable {
+	for _, m := range t.properties {
+		if c, ok := m.value.(*schemaTable); ok && m.key == key {
+			return c
+		}
+	}
+	c := &schemaTable{}
+	t.properties = append(t.properties, schemaMember{key, c})
+	return c
+}
+
+// object returns the members of the schema of t.
+func (t *schemaTable) object() schemaObject {
+	o := schemaObject{{"type", "object"}, {"properties", t.properties}}
+	if len(t.required) > 0 {
+		o = append(o, schemaMember{"required", t.required})
+	}
+	return append(o, schemaMember{"additionalProperties", false})
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t *schemaTable) MarshalJSON() ([]byte, error) {
+	return t.object().MarshalJSON()
+}
+
+// WriteJSONSchema writes a JSON Schema (draft 2020-12) describing the
+// configuration files accepted for the struct passed to conf. Members with
+// flags are described by their type, default value, usage, oneof values and
+// min and max bounds, within nested objects for nested tables. The names of
+// their flags and environment variables are given by the x-flag and x-env
+// extensions. Members tagged required:"true" are required, members tagged
+// secret:"true" are write-only, without a default, and members tagged with
+// deprecated are marked deprecated.
+func (s *FlagSet) WriteJSONSchema(w io.Writer, conf interface{}) error {
+	var members []structField
+	names := map[string]bool{}
+
+	err := s.walk(conf, func(sf structField) error {
+		if sf.Name != "_" && sf.name != "" {
+			members = append(members, sf)
+			names[sf.name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	root := &schemaTable{}
+	for _, sf := range members {
+		o, err := memberSchema(sf)
+		if err != nil {
+			return err
+		}
+
+		tables, key := splitName(sf.name, names)
+		t := root
+		for _, table := range tables {
+			t = t.table(table)
+		}
+		t.properties = append(t.properties, schemaMember{key, o})
+		if sf.Tag.Get("required") == "true" {
+			t.required = append(t.required, key)
+		}
+	}
+
+	schema := append(schemaObject{
+		{"$schema", jsonSchemaDialect},
+		{"title", s.configName()},
+	}, root.object()...)
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// memberSchema returns the schema of a struct member.
+func memberSchema(sf structField) (schemaObject, error) {
+	v, err := valueFromPointer(withDefault(sf).Addr().Interface())
+	if err != nil {
+		return nil, err
+	}
+	f := &field{value: v}
+	err = f.parseConstraints(sf)
+	if err != nil {
+		return nil, err
+	}
+
+	var o schemaObject
+	typ := schemaType(v)
+	if typ != "" {
+		o = append(o, schemaMember{"type", typ})
+	}
+
+	// Enumerations apply to the elements of arrays.
+	e := v
+	if sv, ok := v.(sliceValue); ok {
+		e, _ = valueFromPointer(reflect.New(sv.v.Type().Elem()).Interface())
+		var items schemaObject
+		if typ := schemaType(e); typ != "" {
+			items = append(items, schemaMember{"type", typ})
+		}
+		if len(f.oneof) > 0 {
+			items = append(items, schemaMember{"enum", schemaEnum(e, f.oneof)})
+		}
+		o = append(o, schemaMember{"items", items})
+	} else if len(f.oneof) > 0 {
+		o = append(o, schemaMember{"enum", schemaEnum(e, f.oneof)})
+	}
+
+	if _, usage := unquoteUsage(sf.Tag.Get("usage"), v.Get()); usage != "" {
+		o = append(o, schemaMember{"description", usage})
+	}
+	if sf.Tag.Get("deprecated") != "" {
+		o = append(o, schemaMember{"deprecated", true})
+	}
+	if sf.tag("secret") == "true" {
+		o = append(o, schemaMember{"writeOnly", true})
+	} else {
+		o = append(o, schemaMember{"default", json.RawMessage(jsonValue(v))})
+	}
+
+	// Durations have no bounds in JSON Schema.
+	if _, ok := v.Get().(time.Duration); !ok {
+		bounds := []struct{ tag, number, length, items string }{
+			{f.min, "minimum", "minLength", "minItems"},
+			{f.max, "maximum", "maxLength", "maxItems"},
+		}
+		for _, bound := range bounds {
+			if bound.tag == "" {
+				continue
+			}
+			n, _ := parseBound(v, bound.tag)
+			switch typ {
+			case "integer", "number":
+				o = append(o, schemaMember{bound.number, n})
+			case "string":
+				o = append(o, schemaMember{bound.length, int(n)})
+			case "array":
+				o = append(o, schemaMember{bound.items, int(n)})
+			}
+		}
+	}
+
+	o = append(o, schemaMember{"x-flag", sf.name})
+	if sf.env != "" {
+		o = append(o, schemaMember{"x-env", sf.env})
+	}
+	return o, nil
+}
+
+// schemaType returns the JSON Schema type of the values of v, if known.
+func schemaType(v Value) string {
+	if _, ok := v.(sliceValue); ok {
+		return "array"
+	}
+
+	switch v.Get().(type) {
+	case bool:
+		return "boolean"
+	case int, int64, uint, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case string, time.Duration:
+		return "string"
+	}
+	return ""
+}
+
+// schemaEnum returns the values of a oneof tag as JSON values of the type of
+// v.
+func schemaEnum(v Value, oneof []string) []json.RawMessage {
+	a := make([]json.RawMessage, len(oneof))
+	for i, s := range oneof {
+		c, _ := canonical(v, s)
+		switch schemaType(v) {
+		case "boolean", "integer", "number":
+			a[i] = json.RawMessage(c)
+		default:
+			a[i], _ = json.Marshal(c)
+		}
+	}
+	return a
+}
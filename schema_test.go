@@ -0,0 +1,58 @@
+package flagstruct
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestWriteJSONSchema(t *testing.T) {
+	conf := struct {
+		Name     string        `flag:"name" env:"SCHEMA_NAME" usage:"~name~ of the service" required:"true" max:"20"`
+		Level    string        `flag:"level" oneof:"debug info warn"`
+		Port     int           `flag:"port" min:"1" max:"65535"`
+		Timeout  time.Duration `flag:"timeout" min:"1s"`
+		Token    string        `env:"SCHEMA_TOKEN"`
+		Modes    []int         `flag:"db.modes" oneof:"1 2 3" max:"2"`
+		Password string        `flag:"db.password" secret:"true"`
+	}{Level: "info", Port: 80, Timeout: time.Second, Password: "hunter2"}
+
+	expected := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "program",
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "description": "name of the service", "default": "", "maxLength": 20, "x-flag": "name", "x-env": "SCHEMA_NAME"},
+    "level": {"type": "string", "enum": ["debug", "info", "warn"], "default": "info", "x-flag": "level"},
+    "port": {"type": "integer", "default": 80, "minimum": 1, "maximum": 65535, "x-flag": "port"},
+    "timeout": {"type": "string", "default": "1s", "x-flag": "timeout"},
+    "db": {
+      "type": "object",
+      "properties": {
+        "modes": {"type": "array", "items": {"type": "integer", "enum": [1, 2, 3]}, "default": [], "maxItems": 2, "x-flag": "db.modes"},
+        "password": {"type": "string", "writeOnly": true, "x-flag": "db.password"}
+      },
+      "additionalProperties": false
+    }
+  },
+  "required": ["name"],
+  "additionalProperties": false
+}`
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	err := s.WriteJSONSchema(&buf, &conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compact := bytes.Buffer{}
+	json.Compact(&compact, []byte(expected))
+	actual := bytes.Buffer{}
+	json.Compact(&actual, buf.Bytes())
+	if actual.String() != compact.String() {
+		t.Errorf("unexpected schema:\n%s", buf.String())
+	}
+}
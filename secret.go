@@ -0,0 +1,93 @@
+package flagstruct
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileEnvSuffix is appended to environment keys to name the variables holding
+// the paths of files to read values from.
+const fileEnvSuffix = "_FILE"
+
+// SetFileEnv sets whether environment keys may be read from files. If enabled,
+// a variable named KEY_FILE holds the path of a file whose contents, without a
+// trailing newline, are used as the value of KEY. Setting both KEY and KEY_FILE
+// is an error.
+func (s *FlagSet) SetFileEnv(enabled bool) {
+	s.fileEnv = enabled
+}
+
+// DefaultSecretDirs returns the directories searched for secrets by default:
+// /run/secrets, where Docker mounts secrets, and $CREDENTIALS_DIRECTORY, where
+// systemd provides credentials, if set.
+func DefaultSecretDirs() []string {
+	return defaultSecretDirs(os.LookupEnv)
+}
+
+// defaultSecretDirs is DefaultSecretDirs, looking up environment variables
+// with lookupEnv.
+func defaultSecretDirs(lookupEnv func(key string) (string, bool)) []string {
+	dirs := []string{"/run/secrets"}
+	if dir, _ := lookupEnv("CREDENTIALS_DIRECTORY"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// SetSecretDirs enables reading environment keys from files in the
+// directories dirs, named after the key or its lowercase form. The first file
+// found is used, and only if the environment does not define the key. If no
+// directories are given, DefaultSecretDirs is used, with the environment looked
+// up as set by WithLookupEnv.
+func (s *FlagSet) SetSecretDirs(dirs ...string) {
+	if len(dirs) == 0 {
+		dirs = defaultSecretDirs(s.lookupEnvFunc)
+	}
+	s.secretDirs = dirs
+}
+
+// envValue looks up the value of the environment key, from the environment,
+// from files named by KEY_FILE variables or from secret directories.
+func (s *FlagSet) envValue(key string) (value, origin string, ok bool, err error) {
+	value, origin, ok = s.lookupEnv(key)
+
+	if s.fileEnv {
+		if p, _, fok := s.lookupEnv(key + fileEnvSuffix); fok {
+			if ok {
+				return "", "", false, fmt.Errorf("both $%s and $%s%s are set", key, key, fileEnvSuffix)
+			}
+			value, origin, err = s.readSecret(p)
+			return value, origin, true, err
+		}
+	}
+
+	if ok {
+		return value, origin, true, nil
+	}
+
+	for _, dir := range s.secretDirs {
+		for _, name := range []string{key, strings.ToLower(key)} {
+			value, origin, err = s.readSecret(filepath.Join(dir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return value, origin, true, err
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// readSecret reads the secret in the file at p, returning its absolute path.
+func (s *FlagSet) readSecret(p string) (value, origin string, err error) {
+	p, b, err := s.readFile(p)
+	if err != nil {
+		return "", p, err
+	}
+	value = strings.TrimSuffix(string(b), "\n")
+	return strings.TrimSuffix(value, "\r"), p, nil
+}
@@ -0,0 +1,129 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+func TestDefaultSecretDirs(t *testing.T) {
+	os.Setenv("CREDENTIALS_DIRECTORY", "/run/credentials/app.service")
+	defer os.Unsetenv("CREDENTIALS_DIRECTORY")
+
+	dirs := DefaultSecretDirs()
+	expected := []string{"/run/secrets", "/run/credentials/app.service"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("DefaultSecretDirs returned %q, expected %q", dirs, expected)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	conf := struct {
+		Password string `env:"SECRET_PASSWORD"`
+		Token    string `env:"SECRET_TOKEN"`
+		APIKey   string `env:"SECRET_API_KEY"`
+		User     string `env:"SECRET_USER"`
+	}{}
+
+	fsys := fstest.MapFS{
+		"files/password":           {Data: []byte("hunter2\n")},
+		"run/secrets/secret_token": {Data: []byte("token\r\n")},
+		"run/secrets/SECRET_USER":  {Data: []byte("secret")},
+		"creds/secret_api_key":     {Data: []byte("key\n\n")},
+		"creds/secret_token":       {Data: []byte("other")},
+	}
+
+	os.Setenv("SECRET_PASSWORD_FILE", "/files/password")
+	os.Setenv("SECRET_USER", "env")
+	defer os.Unsetenv("SECRET_PASSWORD_FILE")
+	defer os.Unsetenv("SECRET_USER")
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetFileEnv(true)
+	s.SetSecretDirs("/run/secrets", "/creds")
+	err := s.Configure(&conf, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Password != "hunter2" || conf.Token != "token" || conf.APIKey != "key\n" || conf.User != "env" {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	origins := map[string]string{
+		"Password": "/files/password",
+		"Token":    "/run/secrets/secret_token",
+		"User":     "$SECRET_USER",
+	}
+	for path, expected := range origins {
+		if origin := s.Origin(path); origin != expected {
+			t.Errorf("Origin(%q) = %q, expected %q", path, origin, expected)
+		}
+	}
+
+	os.Setenv("SECRET_PASSWORD", "env")
+	defer os.Unsetenv("SECRET_PASSWORD")
+	err = s.ParseEnv()
+	if err == nil || err.Error() != "both $SECRET_PASSWORD and $SECRET_PASSWORD_FILE are set" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	os.Unsetenv("SECRET_PASSWORD")
+	os.Setenv("SECRET_PASSWORD_FILE", "/files/missing")
+	err = s.ParseEnv()
+	if err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	// Invalid values read from files are not quoted in errors.
+	fsys["files/port"] = &fstest.MapFile{Data: []byte("hunter2\n")}
+	os.Setenv("SECRET_PORT_FILE", "/files/port")
+	defer os.Unsetenv("SECRET_PORT_FILE")
+	port := struct {
+		Port int `env:"SECRET_PORT"`
+	}{}
+	s = NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.SetFileEnv(true)
+	err = s.Configure(&port, []string{})
+	if err == nil || err.Error() != "$SECRET_PORT in /files/port: invalid value, expected int" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// Without SetFileEnv, KEY_FILE variables are ignored.
+	conf.Password = ""
+	s = NewFlagSet("program", flag.ContinueOnError)
+	s.SetConfigFS(fsys)
+	s.Configure(&conf, []string{})
+	if conf.Password != "" {
+		t.Errorf("unexpected password %q", conf.Password)
+	}
+}
+
+func TestSecretUsage(t *testing.T) {
+	conf := struct {
+		Password string `flag:"password" secret:"true"`
+	}{Password: "hunter2"}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	err := s.Struct(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Usage()
+	s.PrintDefaults()
+	s.SetUsageTemplate(template.Must(template.New("usage").Parse(DefaultUsageTemplate)))
+	s.MakeUsage()()
+
+	if strings.Contains(buf.String(), "hunter2") || !strings.Contains(buf.String(), "(default <redacted>)") {
+		t.Errorf("secret default not redacted:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,39 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in    string
+		words []string
+	}{
+		{"", nil},
+		{"  -a  -b=1 ", []string{"-a", "-b=1"}},
+		{`-out='a b' -x`, []string{"-out=a b", "-x"}},
+		{`-out="a \"b\" \c"`, []string{`-out=a "b" \c`}},
+		{`-out=a\ b\\`, []string{`-out=a b\`}},
+		{`'' ""`, []string{"", ""}},
+		{"-a \\\n-b", []string{"-a", "-b"}},
+		{`-msg='it'\''s'`, []string{"-msg=it's"}},
+	}
+
+	for _, test := range tests {
+		words, err := splitWords(test.in)
+		if err != nil {
+			t.Errorf("splitWords(%q) returned error %v", test.in, err)
+		}
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("splitWords(%q) = %q, expected %q", test.in, words, test.words)
+		}
+	}
+
+	for _, in := range []string{`-a 'b`, `-a "b`, `"\"`} {
+		_, err := splitWords(in)
+		if err != errUnterminatedQuote {
+			t.Errorf("splitWords(%q) returned error %v, expected %v", in, err, errUnterminatedQuote)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package flagstruct
+
+import "reflect"
+
+// A Snapshot holds the values of the members loaded by a flag set, and where
+// they were set from, at the time it was taken.
+type Snapshot struct {
+	values  []reflect.Value
+	origins []string
+	warned  []bool
+}
+
+// Snapshot captures the current values of the members loaded by Struct, along
+// with their origins, so they can be rolled back with Restore, for example
+// when a new configuration fails validation.
+func (s *FlagSet) Snapshot() *Snapshot {
+	snap := &Snapshot{}
+	for _, f := range s.fields {
+		snap.values = append(snap.values, copyValue(f.member))
+		snap.origins = append(snap.origins, f.origin)
+		snap.warned = append(snap.warned, f.warned)
+	}
+	return snap
+}
+
+// Restore sets the members loaded by Struct, and their origins, back to the
+// state captured by snap, so that deprecated members set since then warn
+// again. Members loaded after the snapshot was taken are left unchanged.
+func (s *FlagSet) Restore(snap *Snapshot) {
+	for i, f := range s.fields {
+		if i >= len(snap.values) {
+			break
+		}
+		f.member.Set(copyValue(snap.values[i]))
+		f.origin = snap.origins[i]
+		f.warned = snap.warned[i]
+	}
+}
+
+// Reset sets the members loaded by Struct back to their defaults: their
+// values when Struct was called, or their default tags.
+func (s *FlagSet) Reset() {
+	for _, f := range s.fields {
+		f.member.Set(copyValue(f.initial))
+		f.origin = originDefault
+		f.warned = false
+	}
+}
+
+// copyValue returns a deep copy of v, which does not share the elements of
+// slices, maps and pointers, nor those of exported struct fields. Unexported
+// struct fields, interfaces, functions and channels are copied as is.
+func copyValue(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		c.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(copyValue(iter.Key()), copyValue(iter.Value()))
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			break
+		}
+		c.Set(reflect.New(v.Type().Elem()))
+		c.Elem().Set(copyValue(v.Elem()))
+	case reflect.Struct:
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+	default:
+		c.Set(v)
+	}
+	return c
+}
@@ -0,0 +1,104 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// labels is a custom value holding a map, set from key=value pairs.
+type labels map[string]string
+
+func (l *labels) Set(s string) error {
+	k, v, _ := strings.Cut(s, "=")
+	if *l == nil {
+		*l = labels{}
+	}
+	(*l)[k] = v
+	return nil
+}
+func (l *labels) Get() interface{} { return map[string]string(*l) }
+func (l *labels) String() string   { return fmt.Sprint(map[string]string(*l)) }
+
+func TestSnapshot(t *testing.T) {
+	type config struct {
+		Name string   `flag:"name" default:"api"`
+		Port int      `flag:"port"`
+		Tags []string `flag:"tags"`
+	}
+	conf := config{Port: 80, Tags: []string{"a"}}
+
+	s := NewFlagSet("program", flag.ContinueOnError)
+	err := s.Configure(&conf, []string{"-port=8080", "-tags=b,c"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	snap := s.Snapshot()
+	err = s.Parse([]string{"-name=web", "-tags=d"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	conf.Tags[0] = "e"
+
+	s.Restore(snap)
+	expected := config{Name: "api", Port: 8080, Tags: []string{"b", "c"}}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("restored configuration differs.\nexpected: %+v\nactual:   %+v", expected, conf)
+	}
+	if s.Origin("Name") != originDefault || s.Origin("Port") != originFlag {
+		t.Errorf("unexpected origins %q, %q", s.Origin("Name"), s.Origin("Port"))
+	}
+
+	s.Reset()
+	expected = config{Name: "api", Port: 80, Tags: []string{"a"}}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("reset configuration differs.\nexpected: %+v\nactual:   %+v", expected, conf)
+	}
+	if s.Origin("Port") != originDefault {
+		t.Errorf("unexpected origin %q", s.Origin("Port"))
+	}
+}
+
+func TestSnapshotDeep(t *testing.T) {
+	conf := struct {
+		Labels labels `flag:"label"`
+		Old    string `flag:"old" deprecated:"use -new"`
+	}{Labels: labels{"env": "prod"}}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithOutput(&buf))
+	err := s.Configure(&conf, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	snap := s.Snapshot()
+	err = s.Parse([]string{"-label=env=dev", "-label=zone=a", "-old=x"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	s.Restore(snap)
+	if !reflect.DeepEqual(conf.Labels, labels{"env": "prod"}) {
+		t.Errorf("unexpected labels %v", conf.Labels)
+	}
+
+	// Deprecated members set again after a restore warn again.
+	err = s.Parse([]string{"-old=y"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := strings.Repeat("flag -old is deprecated: use -new\n", 2)
+	if buf.String() != expected {
+		t.Errorf("unexpected warnings %q", buf.String())
+	}
+
+	s.Reset()
+	if !reflect.DeepEqual(conf.Labels, labels{"env": "prod"}) {
+		t.Errorf("unexpected labels %v", conf.Labels)
+	}
+}
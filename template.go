@@ -0,0 +1,237 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"text/template"
+)
+
+// DefaultUsageTemplate is the usage template rendering the default output of
+// the usage functions made by MakeStructUsage and MakeUsage. Its "groups"
+// block renders the output of PrintStruct.
+const DefaultUsageTemplate = `{{if .Name}}Usage of {{.Name}}:{{else}}Usage:{{end}}
+{{- with .Args}}
+  {{with $.Name}}{{.}} {{end}}[flags] {{.}}
+{{- end}}
+{{block "groups" .Groups}}
+{{- range $i, $group := .}}
+{{- if $i}}
+{{end}}
+{{- with .Title}}{{.}}:
+{{end}}
+{{- range .DescriptionLines}}  {{.}}
+{{end}}
+{{- range .Flags}}  {{.Line}}
+{{end}}
+{{- end}}
+{{- end}}
+{{- with .Subcommands}}
+Commands:
+{{range .}}  {{.Name}}	{{.Usage}}
+{{end}}
+{{- end}}
+{{- with .ConfigFiles}}
+Configuration files:
+{{range .}}  {{.}}
+{{end}}
+{{- end}}`
+
+// defaultUsageTemplate is DefaultUsageTemplate, parsed.
+var defaultUsageTemplate = template.Must(template.New("usage").Parse(DefaultUsageTemplate))
+
+// UsageData is the data usage templates are executed with.
+type UsageData struct {
+	// Name is the name of the flag set.
+	Name string
+	// Args is the usage of the positional arguments, see SetArgsUsage.
+	Args string
+	// Groups holds the flags, in sections.
+	Groups []UsageGroup
+	// Subcommands lists the subcommands added with Subcommand.
+	Subcommands []UsageCommand
+	// ConfigFiles lists the configuration files and key directories loaded.
+	ConfigFiles []string
+}
+
+// UsageGroup is a section of flags, started by a "_" separator or formed by
+// a nested struct. The first section is untitled.
+type UsageGroup struct {
+	Title       string
+	Description string
+	// DescriptionLines is the description, wrapped to the width set by
+	// SetHelpWidth.
+	DescriptionLines []string
+	Flags            []UsageFlag
+}
+
+// UsageFlag describes a flag in usage templates.
+type UsageFlag struct {
+	// Name is the name of the flag, without dash.
+	Name string
+	// Aliases are the names of the aliases of the flag, see Alias.
+	Aliases []string
+	// Type is the name of the value of the flag, empty for booleans.
+	Type string
+	// Default is the default value, empty if it is the zero value. Strings
+	// are quoted, and secrets redacted.
+	Default  string
+	Usage    string
+	Env      string
+	Required bool
+	Secret   bool
+	// Reload is the reload policy of the flag, if set.
+	Reload string
+	// Deprecated is the deprecation note of the flag, if set. Deprecated
+	// flags are only listed in verbose help, see SetHelpVerbose.
+	Deprecated string
+	// Value and Origin are the current value of the flag and where it was
+	// set from, as described by Origin, in live help. Secrets are redacted.
+	Value  string
+	Origin string
+	// Line is the flag as printed by PrintStruct: its names, type and
+	// environment variable, followed by its usage and the notes above, laid
+	// out as set by SetHelpWidth and SetHelpEnv. It may span several lines.
+	Line string
+}
+
+// UsageCommand describes a subcommand in usage templates.
+type UsageCommand struct {
+	Name  string
+	Usage string
+}
+
+// SetUsageTemplate sets a template rendering the output of the usage
+// functions made by MakeStructUsage and MakeUsage, executed with UsageData.
+// DefaultUsageTemplate may be used as a starting point. A nil template
+// restores DefaultUsageTemplate. Usage functions made by MakeStructUsage use
+// the template set when they are made.
+func (s *FlagSet) SetUsageTemplate(t *template.Template) {
+	s.usageTemplate = t
+}
+
+// flagGroups returns the registered flags, in a single section.
+func (s *FlagSet) flagGroups() []*helpGroup {
+	g := &helpGroup{}
+	s.VisitAll(func(f *flag.Flag) {
+		if s.listed(f.Name) && s.aliases[f.Name] == "" {
+			g.entries = append(g.entries, s.flagEntry(f))
+		}
+	})
+	return []*helpGroup{g}
+}
+
+// otherFlagsTitle titles the section of the flags not loaded from structs.
+const otherFlagsTitle = "Other flags"
+
+// otherGroup returns the registered flags not loaded from structs nor defined
+// by the flag set itself, in a section titled "Other flags", or nil if there
+// are none.
+func (s *FlagSet) otherGroup() *helpGroup {
+	g := &helpGroup{title: otherFlagsTitle}
+	s.VisitAll(func(f *flag.Flag) {
+		if s.lookup(f.Name) == nil && s.aliases[f.Name] == "" && !s.owns(f.Name) {
+			g.entries = append(g.entries, s.flagEntry(f))
+		}
+	})
+	if len(g.entries) == 0 {
+		return nil
+	}
+	return g
+}
+
+// ownEntries describes the flags defined by the flag set itself, such as the
+// flag of HelpLiveFlag.
+func (s *FlagSet) ownEntries() []helpEntry {
+	var entries []helpEntry
+	for _, name := range s.ownFlags {
+		if f := s.Lookup(name); f != nil {
+			entries = append(entries, s.flagEntry(f))
+		}
+	}
+	return entries
+}
+
+// owns reports whether the flag named name is defined by the flag set itself.
+func (s *FlagSet) owns(name string) bool {
+	for _, n := range s.ownFlags {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// flagEntry describes a registered flag.
+func (s *FlagSet) flagEntry(f *flag.Flag) helpEntry {
+	e := helpEntry{name: f.Name, aliases: s.aliasesOf(f.Name), def: f.DefValue}
+	var val interface{}
+	if g, ok := f.Value.(flag.Getter); ok {
+		val = g.Get()
+	}
+	e.typn, e.usage = unquoteUsage(f.Usage, val)
+	if isZeroValue(f.DefValue) {
+		e.def = ""
+	} else if _, ok := val.(string); ok {
+		e.def = fmt.Sprintf("%q", f.DefValue)
+	}
+	if fv := s.lookup(f.Name); fv != nil {
+		e.env, e.required, e.secret, e.deprecated = fv.env, fv.required, fv.secret, fv.deprecated
+	}
+	if e.secret && e.def != "" {
+		e.def = redacted
+	}
+	return e
+}
+
+// usageData returns the data usage templates are executed with, listing the
+// flags of groups. Sections without a title, description or flags are left
+// out.
+func (s *FlagSet) usageData(groups []*helpGroup) UsageData {
+	data := UsageData{Name: s.name, Args: s.argsUsage, Subcommands: s.subcommands, ConfigFiles: s.configFiles}
+	col, width := s.helpLayout(groups)
+	for _, g := range groups {
+		if g.title == "" && g.description == "" && len(g.entries) == 0 {
+			continue
+		}
+		ug := UsageGroup{Title: g.title, Description: g.description}
+		if g.description != "" {
+			lineWidth := 0
+			if width != 0 {
+				lineWidth = width - 2
+			}
+			ug.DescriptionLines = wrapText(g.description, lineWidth)
+		}
+		for _, e := range g.entries {
+			ug.Flags = append(ug.Flags, UsageFlag{
+				Name:       e.name,
+				Aliases:    e.aliases,
+				Type:       e.typn,
+				Default:    e.def,
+				Usage:      e.usage,
+				Env:        e.env,
+				Required:   e.required,
+				Secret:     e.secret,
+				Reload:     e.reload,
+				Deprecated: e.deprecated,
+				Value:      e.current,
+				Origin:     e.origin,
+				Line:       s.entryLine(e, col, width),
+			})
+		}
+		data.Groups = append(data.Groups, ug)
+	}
+	return data
+}
+
+// executeUsage executes a usage template with data, printing errors to the
+// output of the flag set. A nil template is DefaultUsageTemplate.
+func (s *FlagSet) executeUsage(t *template.Template, data UsageData) {
+	if t == nil {
+		t = defaultUsageTemplate
+	}
+	data.ConfigFiles = s.configFiles
+	err := t.Execute(s.out(), data)
+	if err != nil {
+		fmt.Fprintln(s.out(), err)
+	}
+}
@@ -0,0 +1,124 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+func TestDefaultUsageTemplate(t *testing.T) {
+	type dbConfig struct {
+		Host string `flag:"host" usage:"database host" reload:"static"`
+	}
+	conf := struct {
+		X          bool      `flag:"x"`
+		Bool       bool      `flag:"test_bool" usage:"bool value"`
+		Str        string    `flag:"test_str"`
+		_          struct{}  `group:"Custom" description:"Custom values."`
+		TestCustom customVal `flag:"test_custom" usage:"~custom~ value"`
+		_          struct{}
+		DB         dbConfig `flag:"db" group:"Database"`
+	}{X: true, Str: "x"}
+
+	for _, name := range []string{"program", ""} {
+		s := NewFlagSet(name, flag.ContinueOnError, WithHelpWidth(60), WithHelpEnv(true))
+		s.SetConfigFS(fstest.MapFS{"conf.json": {Data: []byte(`{"test_str": "y"}`)}})
+		s.Struct(&conf)
+
+		expected, actual := bytes.Buffer{}, bytes.Buffer{}
+		s.SetOutput(&expected)
+		s.MakeStructUsage(&conf)()
+		s.MakeUsage()()
+
+		s.SetOutput(&actual)
+		s.SetUsageTemplate(template.Must(template.New("usage").Parse(DefaultUsageTemplate)))
+		s.MakeStructUsage(&conf)()
+		s.MakeUsage()()
+
+		if actual.String() != expected.String() {
+			t.Errorf("template output differs from default.\nexpected:\n%q\nactual:\n%q\n", expected.String(), actual.String())
+		}
+
+		// Configuration files are listed when usage is printed.
+		usage := s.MakeStructUsage(&conf)
+		s.SetUsageTemplate(nil)
+		defaultUsage := s.MakeStructUsage(&conf)
+		s.LoadConfig("/conf.json")
+
+		expected.Reset()
+		actual.Reset()
+		usage()
+		s.SetOutput(&expected)
+		defaultUsage()
+		if actual.String() != expected.String() {
+			t.Errorf("template output differs from default.\nexpected:\n%q\nactual:\n%q\n", expected.String(), actual.String())
+		}
+	}
+}
+
+func TestUsageTemplate(t *testing.T) {
+	conf := struct {
+		Name  string `flag:"name" env:"TEMPLATE_NAME" usage:"service name" required:"true"`
+		Token string `flag:"token" secret:"true"`
+	}{}
+
+	tmpl := template.Must(template.New("usage").Parse(`{{.Name}}
+{{range .Groups}}{{range .Flags}}{{.Name}} {{.Type}} {{.Env}} {{.Required}} {{.Secret}}: {{.Usage}}
+{{end}}{{end}}See https://example.com.
+`))
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError)
+	s.SetOutput(&buf)
+	s.SetUsageTemplate(tmpl)
+	s.Struct(&conf)
+	s.MakeStructUsage(&conf)()
+
+	expected := "program\nname string TEMPLATE_NAME true false: service name\ntoken string  false true: \nSee https://example.com.\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%q", buf.String())
+	}
+
+	buf.Reset()
+	s.SetUsageTemplate(template.Must(template.New("usage").Parse("{{.Missing}}")))
+	s.MakeStructUsage(&conf)()
+	if buf.Len() == 0 {
+		t.Error("expected template error to be printed")
+	}
+}
+
+func TestUsageCommands(t *testing.T) {
+	conf := struct {
+		Port    int  `flag:"port" usage:"listening port"`
+		Verbose bool `flag:"verbose"`
+	}{Port: 80}
+
+	buf := bytes.Buffer{}
+	s := NewFlagSet("program", flag.ContinueOnError, WithArgsUsage("FILE..."))
+	s.SetOutput(&buf)
+	s.Struct(&conf)
+	s.Alias("p", "port")
+	s.Alias("v", "verbose")
+	s.Alias("V", "v")
+	s.Subcommand("serve", "serve the files")
+	s.Subcommand("check", "check the files")
+	s.MakeStructUsage(&conf)()
+
+	expected := "Usage of program:\n  program [flags] FILE...\n" +
+		"  -port, -p int\n    \tlistening port (default 80)\n" +
+		"  -verbose, -V, -v\n    \t\n" +
+		"\nCommands:\n  serve\tserve the files\n  check\tcheck the files\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%q", buf.String())
+	}
+
+	err := s.Parse([]string{"-p", "8080", "-V"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Port != 8080 || !conf.Verbose {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+}
@@ -0,0 +1,433 @@
+package flagstruct
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This is a decoder for the subset of TOML used by configuration files: tables,
+// dotted keys, inline tables, arrays of scalars and all scalar types, which are
+// kept as strings. Arrays of tables are not supported.
+
+type tomlDecoder struct {
+	b   []byte
+	off int
+}
+
+func decodeTOML(b []byte) (map[string]interface{}, error) {
+	d := &tomlDecoder{b: b}
+	root := map[string]interface{}{}
+	table := root
+
+	for {
+		d.skipSpace(true)
+		if d.off >= len(d.b) {
+			return root, nil
+		}
+
+		var err error
+		if d.b[d.off] == '[' {
+			if d.peek("[[") {
+				return nil, d.errorf("arrays of tables are not supported")
+			}
+			d.off++
+			table, err = d.header(root)
+		} else {
+			err = d.keyValue(table)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		d.skipSpace(false)
+		if d.off < len(d.b) && d.b[d.off] != '\n' && !d.peek("\r\n") {
+			return nil, d.errorf("expected newline")
+		}
+	}
+}
+
+// header decodes a table header, after its opening bracket.
+func (d *tomlDecoder) header(root map[string]interface{}) (map[string]interface{}, error) {
+	keys, err := d.key()
+	if err != nil {
+		return nil, err
+	}
+	if !d.peek("]") {
+		return nil, d.errorf("expected ]")
+	}
+	d.off++
+	return d.table(root, keys)
+}
+
+// keyValue decodes a key/value pair into table.
+func (d *tomlDecoder) keyValue(table map[string]interface{}) error {
+	line, col := position(d.b, d.off)
+	keys, err := d.key()
+	if err != nil {
+		return err
+	}
+	if !d.peek("=") {
+		return d.errorf("expected =")
+	}
+	d.off++
+	d.skipSpace(false)
+
+	table, err = d.table(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+
+	key := keys[len(keys)-1]
+	if _, ok := table[key]; ok {
+		return &posError{line, col, "duplicate key " + strconv.Quote(key)}
+	}
+
+	table[key], err = d.value()
+	return err
+}
+
+// table returns the nested table of t named by keys, creating it if needed.
+func (d *tomlDecoder) table(t map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, key := range keys {
+		switch v := t[key].(type) {
+		case nil:
+			m := map[string]interface{}{}
+			t[key] = m
+			t = m
+		case map[string]interface{}:
+			t = v
+		default:
+			return nil, d.errorf("key %q is not a table", key)
+		}
+	}
+	return t, nil
+}
+
+// key decodes a dotted key.
+func (d *tomlDecoder) key() ([]string, error) {
+	var keys []string
+
+	for {
+		d.skipSpace(false)
+		if d.off >= len(d.b) {
+			return nil, d.errorf("expected key")
+		}
+
+		switch c := d.b[d.off]; {
+		case c == '"':
+			s, err := d.basicString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, s)
+		case c == '\'':
+			s, err := d.literalString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, s)
+		default:
+			start := d.off
+			for d.off < len(d.b) && isTOMLBare(d.b[d.off]) {
+				d.off++
+			}
+			if d.off == start {
+				return nil, d.errorf("expected key")
+			}
+			keys = append(keys, string(d.b[start:d.off]))
+		}
+
+		d.skipSpace(false)
+		if !d.peek(".") {
+			return keys, nil
+		}
+		d.off++
+	}
+}
+
+// value decodes a value.
+func (d *tomlDecoder) value() (interface{}, error) {
+	if d.off >= len(d.b) {
+		return nil, d.errorf("expected value")
+	}
+
+	line, col := position(d.b, d.off)
+	switch d.b[d.off] {
+	case '"':
+		s, err := d.basicString()
+		return configValue{s, line, col}, err
+	case '\'':
+		s, err := d.literalString()
+		return configValue{s, line, col}, err
+	case '[':
+		return d.array()
+	case '{':
+		return d.inlineTable()
+	}
+
+	start := d.off
+	d.scalar()
+	// Local date-times may separate the date and time with a space.
+	if d.off-start == 10 && d.b[start+4] == '-' && d.off+1 < len(d.b) && d.b[d.off] == ' ' && isDigit(d.b[d.off+1]) {
+		d.off++
+		d.scalar()
+	}
+
+	s := string(d.b[start:d.off])
+	switch {
+	case s == "true" || s == "false":
+	case s != "" && (isDigit(s[0]) || s[0] == '+' || s[0] == '-' || s == "inf" || s == "nan"):
+	default:
+		d.off = start
+		return nil, d.errorf("invalid value")
+	}
+	return configValue{s, line, col}, nil
+}
+
+// scalar skips over a number, boolean or date-time.
+func (d *tomlDecoder) scalar() {
+	for d.off < len(d.b) && (isTOMLBare(d.b[d.off]) || strings.IndexByte(".+:", d.b[d.off]) >= 0) {
+		d.off++
+	}
+}
+
+// array decodes an array of scalars.
+func (d *tomlDecoder) array() (interface{}, error) {
+	line, col := position(d.b, d.off)
+	a := []configValue{}
+	d.off++
+
+	for {
+		d.skipSpace(true)
+		if d.peek("]") {
+			d.off++
+			return configValue{a, line, col}, nil
+		}
+
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		cv, ok := v.(configValue)
+		if !ok {
+			return nil, &posError{line, col, "inline tables in arrays are not supported"}
+		}
+		if _, ok := cv.value.(string); !ok {
+			return nil, &posError{cv.line, cv.col, "nested arrays are not supported"}
+		}
+		a = append(a, cv)
+
+		d.skipSpace(true)
+		if d.peek(",") {
+			d.off++
+		} else if !d.peek("]") {
+			return nil, d.errorf("expected , or ]")
+		}
+	}
+}
+
+// inlineTable decodes an inline table.
+func (d *tomlDecoder) inlineTable() (interface{}, error) {
+	m := map[string]interface{}{}
+	d.off++
+
+	d.skipSpace(false)
+	if d.peek("}") {
+		d.off++
+		return m, nil
+	}
+
+	for {
+		err := d.keyValue(m)
+		if err != nil {
+			return nil, err
+		}
+
+		d.skipSpace(false)
+		switch {
+		case d.peek(","):
+			d.off++
+		case d.peek("}"):
+			d.off++
+			return m, nil
+		default:
+			return nil, d.errorf("expected , or }")
+		}
+	}
+}
+
+// basicString decodes a basic string, which may span multiple lines.
+func (d *tomlDecoder) basicString() (string, error) {
+	multi := d.peek(`"""`)
+	if multi {
+		d.off += 3
+		d.skipNewline()
+	} else {
+		d.off++
+	}
+
+	var buf strings.Builder
+	for d.off < len(d.b) {
+		c := d.b[d.off]
+		switch {
+		case multi && d.peek(`"""`):
+			d.off += 3
+			// Up to two quotes may directly precede the closing delimiter.
+			for i := 0; i < 2 && d.peek(`"`); i++ {
+				buf.WriteByte('"')
+				d.off++
+			}
+			return buf.String(), nil
+		case !multi && c == '"':
+			d.off++
+			return buf.String(), nil
+		case !multi && c == '\n':
+			return "", d.errorf("unterminated string")
+		case c == '\\':
+			if err := d.escape(&buf, multi); err != nil {
+				return "", err
+			}
+		default:
+			buf.WriteByte(c)
+			d.off++
+		}
+	}
+
+	return "", d.errorf("unterminated string")
+}
+
+// escape decodes an escape sequence in a basic string.
+func (d *tomlDecoder) escape(buf *strings.Builder, multi bool) error {
+	d.off++
+	if d.off >= len(d.b) {
+		return d.errorf("unterminated string")
+	}
+
+	c := d.b[d.off]
+	d.off++
+	switch c {
+	case 'b':
+		buf.WriteByte('\b')
+	case 't':
+		buf.WriteByte('\t')
+	case 'n':
+		buf.WriteByte('\n')
+	case 'f':
+		buf.WriteByte('\f')
+	case 'r':
+		buf.WriteByte('\r')
+	case 'e':
+		buf.WriteByte('\x1b')
+	case '"', '\\':
+		buf.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if d.off+n > len(d.b) {
+			return d.errorf("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(string(d.b[d.off:d.off+n]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return d.errorf("invalid unicode escape")
+		}
+		buf.WriteRune(rune(r))
+		d.off += n
+	default:
+		// A line ending backslash trims all following whitespace.
+		if multi && (c == ' ' || c == '\t' || c == '\r' || c == '\n') {
+			d.off--
+			d.skipSpace(true)
+			return nil
+		}
+		d.off -= 2
+		return d.errorf("invalid escape sequence")
+	}
+	return nil
+}
+
+// literalString decodes a literal string, which may span multiple lines.
+func (d *tomlDecoder) literalString() (string, error) {
+	delim := "'"
+	if d.peek("'''") {
+		delim = "'''"
+	}
+	d.off += len(delim)
+	if delim == "'''" {
+		d.skipNewline()
+	}
+
+	start := d.off
+	for d.off < len(d.b) {
+		switch {
+		case d.peek(delim):
+			end := d.off
+			d.off += len(delim)
+			for i := 0; delim == "'''" && i < 2 && d.peek("'"); i++ {
+				end++
+				d.off++
+			}
+			return string(d.b[start:end]), nil
+		case delim == "'" && d.b[d.off] == '\n':
+			return "", d.errorf("unterminated string")
+		}
+		d.off++
+	}
+
+	return "", d.errorf("unterminated string")
+}
+
+// skipSpace skips whitespace and comments, and newlines if newlines is true.
+func (d *tomlDecoder) skipSpace(newlines bool) {
+	for d.off < len(d.b) {
+		switch d.b[d.off] {
+		case ' ', '\t':
+		case '\r', '\n':
+			if !newlines {
+				if d.b[d.off] == '\r' {
+					d.off++
+					continue
+				}
+				return
+			}
+		case '#':
+			for d.off < len(d.b) && d.b[d.off] != '\n' {
+				d.off++
+			}
+			continue
+		default:
+			return
+		}
+		d.off++
+	}
+}
+
+// skipNewline skips a newline directly following a multi-line delimiter.
+func (d *tomlDecoder) skipNewline() {
+	if d.peek("\r\n") {
+		d.off += 2
+	} else if d.peek("\n") {
+		d.off++
+	}
+}
+
+func (d *tomlDecoder) peek(s string) bool {
+	return bytes.HasPrefix(d.b[d.off:], []byte(s))
+}
+
+func (d *tomlDecoder) errorf(format string, a ...interface{}) error {
+	line, col := position(d.b, d.off)
+	return &posError{line, col, fmt.Sprintf(format, a...)}
+}
+
+func isTOMLBare(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '-'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,88 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	in := `# Server settings.
+host = "example.com" # trailing comment
+port = 8_080
+ratio = 0.5
+debug = true
+literal = 'C:\path'
+escaped = "tab\there \u00e9"
+multi = """
+first \
+  second"""
+raw = '''
+line'''
+started = 1979-05-27 07:32:00
+"quoted key" = 1
+
+[db]
+user = "admin"
+hosts = [
+  "a", # first
+  "b",
+]
+pool.size = 10
+limits = { max = 3, min = 1 }
+`
+	m, err := decodeTOML([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"host":       "example.com",
+		"port":       "8_080",
+		"ratio":      "0.5",
+		"debug":      "true",
+		"literal":    `C:\path`,
+		"escaped":    "tab\there é",
+		"multi":      "first second",
+		"raw":        "line",
+		"started":    "1979-05-27 07:32:00",
+		"quoted key": "1",
+		"db": map[string]interface{}{
+			"user":   "admin",
+			"hosts":  []interface{}{"a", "b"},
+			"pool":   map[string]interface{}{"size": "10"},
+			"limits": map[string]interface{}{"max": "3", "min": "1"},
+		},
+	}
+	if actual := plain(m); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected result\nexpected: %#v\nactual:   %#v", expected, actual)
+	}
+
+	if v := m["db"].(map[string]interface{})["user"].(configValue); v.line != 17 || v.col != 8 {
+		t.Errorf("unexpected position %d:%d", v.line, v.col)
+	}
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"[[a]]\n", "1:1: arrays of tables are not supported"},
+		{"a = 1\na = 2\n", "2:1: duplicate key \"a\""},
+		{"a = 1 b = 2\n", "1:7: expected newline"},
+		{"a 1\n", "1:3: expected ="},
+		{"a = \"x\n", "1:7: unterminated string"},
+		{"a = \"\\q\"\n", "1:6: invalid escape sequence"},
+		{"a = bad\n", "1:5: invalid value"},
+		{"a = [[1]]\n", "1:6: nested arrays are not supported"},
+		{"a = [1 2]\n", "1:8: expected , or ]"},
+		{"a = 1\n[a]\n", "2:4: key \"a\" is not a table"},
+		{"[a\n", "1:3: expected ]"},
+	}
+
+	for _, test := range tests {
+		_, err := decodeTOML([]byte(test.in))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("decodeTOML(%q) returned error %v, expected %v", test.in, err, test.err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -139,6 +140,53 @@ func (d *durationValue) Get() interface{} { return time.Duration(*d) }
 // String implements the Value interface.
 func (d *durationValue) String() string { return (*time.Duration)(d).String() }
 
+// sliceValue represents a slice of any of the other supported types.
+type sliceValue struct {
+	v reflect.Value
+}
+
+// Set implements the Value interface. The slice is replaced by the
+// comma-separated elements of s.
+func (s sliceValue) Set(val string) error {
+	if val == "" {
+		return s.setSlice(nil)
+	}
+	return s.setSlice(strings.Split(val, ","))
+}
+
+// setSlice replaces the slice with the elements vals.
+func (s sliceValue) setSlice(vals []string) error {
+	v := reflect.MakeSlice(s.v.Type(), len(vals), len(vals))
+	for i, val := range vals {
+		e, err := valueFromPointer(v.Index(i).Addr().Interface())
+		if err != nil {
+			return err
+		}
+		err = e.Set(val)
+		if err != nil {
+			return err
+		}
+	}
+	s.v.Set(v)
+	return nil
+}
+
+// Get implements the Value interface.
+func (s sliceValue) Get() interface{} { return s.v.Interface() }
+
+// String implements the Value interface.
+func (s sliceValue) String() string {
+	if !s.v.IsValid() {
+		return ""
+	}
+	vals := make([]string, s.v.Len())
+	for i := range vals {
+		e, _ := valueFromPointer(s.v.Index(i).Addr().Interface())
+		vals[i] = e.String()
+	}
+	return strings.Join(vals, ",")
+}
+
 // Value is an interface used for flag values.
 type Value interface {
 	String() string
@@ -171,7 +219,13 @@ func valueFromPointer(ptr interface{}) (Value, error) {
 		if ptr == nil {
 			return nil, unhandledTypeError{nil}
 		}
-		t := reflect.ValueOf(ptr).Elem().Interface()
-		return nil, unhandledTypeError{t}
+		v := reflect.ValueOf(ptr).Elem()
+		if v.Kind() == reflect.Slice {
+			_, err := valueFromPointer(reflect.New(v.Type().Elem()).Interface())
+			if err == nil {
+				return sliceValue{v}, nil
+			}
+		}
+		return nil, unhandledTypeError{v.Interface()}
 	}
 }
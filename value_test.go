@@ -203,6 +203,44 @@ func TestDurationValue(t *testing.T) {
 	}
 }
 
+func TestSliceValue(t *testing.T) {
+	d := []time.Duration{time.Second}
+	v, err := valueFromPointer(&d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := v.String()
+	if str != "1s" {
+		t.Errorf("String returned %v, expected %v", str, "1s")
+	}
+
+	err = v.Set("2s,3s")
+	if err != nil {
+		t.Errorf("Set returned %v, expected %v", err, nil)
+	}
+
+	result := v.Get().([]time.Duration)
+	if len(result) != 2 || result[0] != 2*time.Second || result[1] != 3*time.Second {
+		t.Errorf("Get returned %v, expected %v (after Set)", result, "[2s 3s]")
+	}
+
+	err = v.Set("")
+	if err != nil || len(d) != 0 {
+		t.Errorf("Set returned %v with %v, expected empty slice", err, d)
+	}
+
+	err = v.Set("1s,x")
+	if err == nil {
+		t.Error("expected err to not be nil")
+	}
+
+	_, err = valueFromPointer(&[]int16{})
+	if err == nil {
+		t.Error("expected err to not be nil")
+	}
+}
+
 func TestValueFromPointer(t *testing.T) {
 	_, err := valueFromPointer(nil)
 	if err == nil {
@@ -0,0 +1,306 @@
+package flagstruct
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// This is a decoder for the subset of YAML used by configuration files: block
+// mappings, block and flow sequences of scalars, plain and quoted scalars, and
+// comments. Anchors, tags, block scalars and multiple documents are not
+// supported.
+
+// yamlLine is a non-empty line of YAML with comments removed.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlDecoder struct {
+	lines []yamlLine
+	i     int
+}
+
+func decodeYAML(b []byte) (map[string]interface{}, error) {
+	d := &yamlDecoder{}
+
+	for i, text := range strings.Split(string(b), "\n") {
+		text = strings.TrimRight(stripYAMLComment(strings.TrimRight(text, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (len(d.lines) == 0 && trimmed == "---") {
+			continue
+		}
+		if trimmed == "..." {
+			break
+		}
+		if trimmed == "---" {
+			return nil, &posError{i + 1, 1, "multiple documents are not supported"}
+		}
+		indent := len(text) - len(trimmed)
+		if trimmed[0] == '\t' {
+			return nil, &posError{i + 1, indent + 1, "tabs are not allowed in indentation"}
+		}
+		d.lines = append(d.lines, yamlLine{i + 1, indent, trimmed})
+	}
+
+	if len(d.lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	l := d.lines[0]
+	if isYAMLSeqItem(l.text) {
+		return nil, &posError{l.num, l.indent + 1, "expected a mapping"}
+	}
+
+	m, err := d.mapping(l.indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.i < len(d.lines) {
+		l = d.lines[d.i]
+		return nil, &posError{l.num, l.indent + 1, "unexpected indentation"}
+	}
+
+	return m, nil
+}
+
+// mapping decodes a block mapping whose keys are at the given indentation.
+func (d *yamlDecoder) mapping(indent int) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+
+	for d.i < len(d.lines) && d.lines[d.i].indent == indent {
+		l := d.lines[d.i]
+		if isYAMLSeqItem(l.text) {
+			return nil, &posError{l.num, l.indent + 1, "unexpected sequence item"}
+		}
+
+		key, rest, off, err := splitYAMLKey(l.text)
+		if err != nil {
+			return nil, &posError{l.num, l.indent + 1, err.Error()}
+		}
+		if _, ok := m[key]; ok {
+			return nil, &posError{l.num, l.indent + 1, "duplicate key " + strconv.Quote(key)}
+		}
+		d.i++
+
+		if rest != "" {
+			m[key], err = yamlScalar(rest, l.num, l.indent+off+1)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// An empty value is either null or a nested block. Sequences may be
+		// at the same indentation as their key.
+		if d.i < len(d.lines) {
+			next := d.lines[d.i]
+			switch {
+			case next.indent > indent && isYAMLSeqItem(next.text),
+				next.indent == indent && isYAMLSeqItem(next.text):
+				m[key], err = d.sequence(next.indent)
+			case next.indent > indent:
+				m[key], err = d.mapping(next.indent)
+			default:
+				m[key] = configValue{nil, l.num, l.indent + 1}
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			m[key] = configValue{nil, l.num, l.indent + 1}
+		}
+	}
+
+	if d.i < len(d.lines) && d.lines[d.i].indent > indent {
+		l := d.lines[d.i]
+		return nil, &posError{l.num, l.indent + 1, "unexpected indentation"}
+	}
+
+	return m, nil
+}
+
+// sequence decodes a block sequence of scalars at the given indentation.
+func (d *yamlDecoder) sequence(indent int) (configValue, error) {
+	first := d.lines[d.i]
+	seq := configValue{[]configValue{}, first.num, first.indent + 1}
+
+	for d.i < len(d.lines) && d.lines[d.i].indent == indent && isYAMLSeqItem(d.lines[d.i].text) {
+		l := d.lines[d.i]
+		rest := strings.TrimLeft(l.text[1:], " ")
+		col := l.indent + 1 + len(l.text) - len(rest)
+		if rest == "" {
+			return seq, &posError{l.num, l.indent + 1, "nested sequences are not supported"}
+		}
+		if _, _, _, err := splitYAMLKey(rest); err == nil {
+			return seq, &posError{l.num, col, "mappings in sequences are not supported"}
+		}
+
+		v, err := yamlScalar(rest, l.num, col)
+		if err != nil {
+			return seq, err
+		}
+		if _, ok := v.value.([]configValue); ok {
+			return seq, &posError{l.num, col, "nested sequences are not supported"}
+		}
+		seq.value = append(seq.value.([]configValue), v)
+		d.i++
+	}
+
+	return seq, nil
+}
+
+// yamlScalar decodes a scalar or a flow sequence of scalars.
+func yamlScalar(s string, line, col int) (configValue, error) {
+	switch s[0] {
+	case '[':
+		if s[len(s)-1] != ']' {
+			return configValue{}, &posError{line, col, "unterminated flow sequence"}
+		}
+		a := []configValue{}
+		inner := s[1 : len(s)-1]
+		off := 1
+		for strings.TrimSpace(inner) != "" {
+			item, rest := splitYAMLFlow(inner)
+			trimmed := strings.TrimSpace(item)
+			itemCol := col + off + strings.Index(item, trimmed)
+			if trimmed == "" {
+				return configValue{}, &posError{line, itemCol, "empty flow sequence item"}
+			}
+			v, err := yamlScalar(trimmed, line, itemCol)
+			if err != nil {
+				return configValue{}, err
+			}
+			if _, ok := v.value.(string); !ok {
+				return configValue{}, &posError{line, itemCol, "nested sequences are not supported"}
+			}
+			a = append(a, v)
+			off += len(item) + 1
+			inner = rest
+		}
+		return configValue{a, line, col}, nil
+	case '{':
+		return configValue{}, &posError{line, col, "flow mappings are not supported"}
+	case '|', '>':
+		return configValue{}, &posError{line, col, "block scalars are not supported"}
+	case '&', '*', '!':
+		return configValue{}, &posError{line, col, "anchors, aliases and tags are not supported"}
+	case '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return configValue{}, &posError{line, col, "invalid double-quoted string"}
+		}
+		return configValue{v, line, col}, nil
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return configValue{}, &posError{line, col, "invalid single-quoted string"}
+		}
+		return configValue{strings.ReplaceAll(s[1:len(s)-1], "''", "'"), line, col}, nil
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return configValue{nil, line, col}, nil
+	}
+	return configValue{s, line, col}, nil
+}
+
+// splitYAMLKey splits a "key: value" line, returning the unquoted key, the
+// value and the offset of the value within s.
+func splitYAMLKey(s string) (key, value string, off int, err error) {
+	i := 0
+	if s[0] == '"' || s[0] == '\'' {
+		i = quoteEnd(s)
+		if i < 0 {
+			return "", "", 0, errors.New("unterminated quoted key")
+		}
+	}
+	for ; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			break
+		}
+	}
+	if i == len(s) {
+		return "", "", 0, errors.New("expected \"key: value\"")
+	}
+
+	key = strings.TrimSpace(s[:i])
+	if key == "" {
+		return "", "", 0, errors.New("empty key")
+	}
+	if key[0] == '"' {
+		key, err = strconv.Unquote(key)
+		if err != nil {
+			return "", "", 0, errors.New("invalid quoted key")
+		}
+	} else if key[0] == '\'' {
+		key = strings.ReplaceAll(key[1:len(key)-1], "''", "'")
+	}
+
+	value = strings.TrimLeft(s[i+1:], " ")
+	return key, value, len(s) - len(value), nil
+}
+
+// splitYAMLFlow splits the first item off a flow sequence body.
+func splitYAMLFlow(s string) (item, rest string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			if j := quoteEnd(s[i:]); j > 0 {
+				i += j - 1
+			}
+		case '[':
+			// Skip nested sequences, which are rejected by the caller.
+			if j := strings.IndexByte(s[i:], ']'); j > 0 {
+				i += j
+			}
+		case ',':
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// quoteEnd returns the offset just past the quoted string at the start of s,
+// or -1 if it is unterminated.
+func quoteEnd(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing comment from a line.
+func stripYAMLComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			// Quotes only start strings at the start of a scalar.
+			if i == 0 || strings.IndexByte(" \t[,:-", s[i-1]) >= 0 {
+				if j := quoteEnd(s[i:]); j > 0 {
+					i += j - 1
+				}
+			}
+		case '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+func isYAMLSeqItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
@@ -0,0 +1,103 @@
+package flagstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+// plain strips positions from decoded tables for comparison.
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for key, e := range v {
+			m[key] = plain(e)
+		}
+		return m
+	case configValue:
+		if a, ok := v.value.([]configValue); ok {
+			l := []interface{}{}
+			for _, e := range a {
+				l = append(l, plain(e))
+			}
+			return l
+		}
+		return v.value
+	}
+	return v
+}
+
+func TestDecodeYAML(t *testing.T) {
+	in := `---
+# Server settings.
+host: example.com  # trailing comment
+port: 8080
+name: "quoted # not a comment\t"
+single: 'it''s'
+empty:
+db:
+  user: admin
+  hosts:
+    - a
+    - "b"
+  tags: [x, 'y, z', "w"]
+list:
+- 1
+- 2
+null: ~
+...
+ignored: true
+`
+	m, err := decodeYAML([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"host":   "example.com",
+		"port":   "8080",
+		"name":   "quoted # not a comment\t",
+		"single": "it's",
+		"empty":  nil,
+		"db": map[string]interface{}{
+			"user":  "admin",
+			"hosts": []interface{}{"a", "b"},
+			"tags":  []interface{}{"x", "y, z", "w"},
+		},
+		"list": []interface{}{"1", "2"},
+		"null": nil,
+	}
+	if actual := plain(m); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected result\nexpected: %#v\nactual:   %#v", expected, actual)
+	}
+
+	if v := m["db"].(map[string]interface{})["user"].(configValue); v.line != 9 || v.col != 9 {
+		t.Errorf("unexpected position %d:%d", v.line, v.col)
+	}
+}
+
+func TestDecodeYAMLErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"- a\n", "1:1: expected a mapping"},
+		{"a: 1\n  b: 2\n", "2:3: unexpected indentation"},
+		{"a: 1\na: 2\n", "2:1: duplicate key \"a\""},
+		{"a\n", "1:1: expected \"key: value\""},
+		{"a: |\n  text\n", "1:4: block scalars are not supported"},
+		{"a: &x 1\n", "1:4: anchors, aliases and tags are not supported"},
+		{"a: {b: 1}\n", "1:4: flow mappings are not supported"},
+		{"a: [1, [2]]\n", "1:8: nested sequences are not supported"},
+		{"a:\n  - b: 1\n", "2:5: mappings in sequences are not supported"},
+		{"a: \"x\n", "1:4: invalid double-quoted string"},
+		{"a: 1\n---\nb: 2\n", "2:1: multiple documents are not supported"},
+		{"a:\n\t- b\n", "2:1: tabs are not allowed in indentation"},
+	}
+
+	for _, test := range tests {
+		_, err := decodeYAML([]byte(test.in))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("decodeYAML(%q) returned error %v, expected %v", test.in, err, test.err)
+		}
+	}
+}